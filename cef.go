@@ -0,0 +1,254 @@
+package uslogs
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const (
+	cefHeader           = "CEF:0|"
+	leefHeader          = "LEEF:2.0|"
+	leefSeparator       = '\t'
+	leefSeparatorField  = "x09"
+	leefTimeFormat      = "2006-01-02T15:04:05.000Z07:00"
+	leefTimeFormatField = "yyyy-MM-dd'T'HH:mm:ss.SSSXXX"
+	defaultEventIDKey   = "event_id"
+)
+
+// appendCEFLine appends the line of the given record as a CEF or LEEF event, and returns the position of the
+// timestamp in it.
+func (l *UnstructuredHandler) appendCEFLine(
+	dst []byte, record slog.Record, handlerAttrs, attrBytes []byte,
+) ([]byte, int, int) {
+	leef := l.format == FormatLEEF
+	if leef {
+		dst = append(dst, leefHeader...)
+	} else {
+		dst = append(dst, cefHeader...)
+	}
+	dst = appendCEFHeaderField(dst, l.deviceVendor)
+	dst = appendCEFHeaderField(dst, l.deviceProduct)
+	dst = appendCEFHeaderField(dst, l.deviceVersion)
+	dst = l.appendEventID(dst, record)
+	if leef {
+		dst = append(dst, leefSeparatorField+"|sev="...)
+		dst = strconv.AppendInt(dst, int64(max(cefSeverity(record.Level), 1)), decimalBase)
+	} else {
+		dst = appendMaskedField(l, dst, "", record.Message, appendCEFHeaderField[[]byte])
+		dst = strconv.AppendInt(dst, int64(cefSeverity(record.Level)), decimalBase)
+		dst = append(dst, '|')
+	}
+	extStart := len(dst)
+	timeStart, timeEnd := len(dst), len(dst)
+	if l.withTime {
+		if leef {
+			dst = append(dst, leefSeparator)
+			dst = append(dst, "devTime="...)
+			timeStart = len(dst)
+			dst = record.Time.UTC().AppendFormat(dst, leefTimeFormat)
+			timeEnd = len(dst)
+			dst = append(dst, leefSeparator)
+			dst = append(dst, "devTimeFormat="+leefTimeFormatField...)
+		} else {
+			dst = append(dst, " rt="...)
+			timeStart = len(dst)
+			dst = strconv.AppendInt(dst, record.Time.UnixMilli(), decimalBase)
+			timeEnd = len(dst)
+		}
+	}
+	if leef {
+		dst = append(dst, leefSeparator)
+		dst = appendCEFKey(dst, l.messageKey)
+		dst = append(dst, '=')
+		dst = appendMaskedField(l, dst, l.messageKey, record.Message, appendLEEFValue)
+	}
+	dst = append(dst, handlerAttrs...)
+	dst = append(dst, attrBytes...)
+	if !leef && len(dst) > extStart {
+		// Every extension pair starts with a space, which is not needed after the header.
+		dst = append(dst[:extStart], dst[extStart+1:]...)
+		timeStart, timeEnd = max(timeStart-1, extStart), max(timeEnd-1, extStart)
+	}
+	return append(dst, '\n'), timeStart, timeEnd
+}
+
+// appendEventID appends the value of the record attribute with the event id key as the signature id, or the
+// message when the record does not have one.
+func (l *UnstructuredHandler) appendEventID(dst []byte, record slog.Record) []byte {
+	found := false
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key != l.eventIDKey {
+			return true
+		}
+		buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+		value := logutils.AppendValue((*buf)[:0], attr.Value)
+		dst = appendMaskedField(l, dst, attr.Key, value, appendCEFHeaderField[[]byte])
+		*buf = value
+		logutils.PutPool(logutils.SimplePool, buf)
+		found = true
+		return false
+	})
+	if !found {
+		dst = appendMaskedField(l, dst, "", record.Message, appendCEFHeaderField[[]byte])
+	}
+	return dst
+}
+
+// appendCEFAttr appends the attribute as an extension pair. Group values are flattened into pairs whose keys
+// are qualified by the group path, and keys are then replaced according to the key mapping.
+func (l *UnstructuredHandler) appendCEFAttr(dst []byte, group []byte, attr slog.Attr) []byte {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		nested := slices.Clip(group)
+		if len(nested) != 0 {
+			nested = append(nested, l.groupSeparator)
+		}
+		nested = append(nested, attr.Key...)
+		for _, groupAttr := range value.Group() {
+			dst = l.appendCEFAttr(dst, nested, groupAttr)
+		}
+		return dst
+	}
+	if len(group) == 0 && attr.Key == l.eventIDKey {
+		return dst
+	}
+	appendValue := appendCEFValue
+	if l.format == FormatLEEF {
+		dst = append(dst, leefSeparator)
+		appendValue = appendLEEFValue
+	} else {
+		dst = append(dst, ' ')
+	}
+	keyStart := len(dst)
+	if len(group) != 0 {
+		dst = append(dst, group...)
+		dst = append(dst, l.groupSeparator)
+	}
+	dst = append(dst, attr.Key...)
+	if mapped, ok := l.keyMapping[string(dst[keyStart:])]; ok {
+		dst = append(dst[:keyStart], mapped...)
+	}
+	// Sanitizing never makes the key longer, so it is done in place.
+	dst = append(appendCEFKey(dst[:keyStart], dst[keyStart:]), '=')
+	if slices.Contains(l.maskedAttrs, attr.Key) {
+		return append(dst, maskedFieldValue...)
+	}
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	raw := logutils.AppendValue((*buf)[:0], value)
+	dst = appendMaskedField(l, dst, attr.Key, raw, appendValue)
+	*buf = raw
+	logutils.PutPool(logutils.SimplePool, buf)
+	return dst
+}
+
+// appendMaskedField applies the masked patterns to the raw field, preceded by "key=" unless the key is empty and
+// followed by the separators of a text line, so patterns match as they would on a text line. Then it appends
+// the masked field with the given escaping. Masking has to run first, as escaping changes the delimiters.
+func appendMaskedField[T string | []byte](
+	l *UnstructuredHandler, dst []byte, key string, field T, appendEscaped func([]byte, []byte) []byte,
+) []byte {
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	scratch := (*buf)[:0]
+	if key != "" {
+		scratch = append(append(scratch, key...), '=')
+	}
+	fieldStart := len(scratch)
+	scratch = append(scratch, field...)
+	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 {
+		scratch = l.partialMasker.Mask(append(scratch, ' ', '\n'), l.partialMaskPatterns)[:len(scratch)]
+	}
+	dst = appendEscaped(dst, scratch[fieldStart:])
+	*buf = scratch
+	logutils.PutPool(logutils.SimplePool, buf)
+	return dst
+}
+
+// appendCEFHeaderField appends the field followed by a pipe, escaping pipes and backslashes and replacing line
+// breaks, which are not allowed in the header, by spaces.
+func appendCEFHeaderField[T string | []byte](dst []byte, field T) []byte {
+	for idx := range len(field) {
+		switch char := field[idx]; char {
+		case '|', '\\':
+			dst = append(dst, '\\', char)
+		case '\n', '\r':
+			dst = append(dst, ' ')
+		default:
+			dst = append(dst, char)
+		}
+	}
+	return append(dst, '|')
+}
+
+// appendCEFValue appends the extension value, escaping equal signs and backslashes, and line breaks as \n and
+// \r.
+func appendCEFValue(dst []byte, value []byte) []byte {
+	for _, char := range value {
+		switch char {
+		case '=', '\\':
+			dst = append(dst, '\\', char)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		default:
+			dst = append(dst, char)
+		}
+	}
+	return dst
+}
+
+// appendLEEFValue appends the attribute value, escaping backslashes, and tabs and line breaks as \t, \n and \r,
+// so values never contain the attribute delimiter.
+func appendLEEFValue(dst []byte, value []byte) []byte {
+	for _, char := range value {
+		switch char {
+		case '\\':
+			dst = append(dst, '\\', '\\')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		default:
+			dst = append(dst, char)
+		}
+	}
+	return dst
+}
+
+// appendCEFKey appends the key, replacing the characters other than letters, digits, underscores and dots by
+// underscores.
+func appendCEFKey[T string | []byte](dst []byte, key T) []byte {
+	if len(key) == 0 {
+		return append(dst, '_')
+	}
+	for idx := range len(key) {
+		char := key[idx]
+		if (char < 'a' || char > 'z') && (char < 'A' || char > 'Z') && (char < '0' || char > '9') &&
+			char != '_' && char != '.' {
+			char = '_'
+		}
+		dst = append(dst, char)
+	}
+	return dst
+}
+
+// cefSeverity maps the level to the 0 to 10 severity scale of CEF and LEEF.
+func cefSeverity(level slog.Level) int {
+	switch {
+	case level > slog.LevelError:
+		return 10 //nolint:mnd
+	case level >= slog.LevelError:
+		return 8 //nolint:mnd
+	case level >= slog.LevelWarn:
+		return 6 //nolint:mnd
+	case level >= slog.LevelInfo:
+		return 3 //nolint:mnd
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,120 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func handleRecord(t *testing.T, handler slog.Handler, record slog.Record) {
+	t.Helper()
+	if err := handler.Handle(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnstructuredHandler_CEF(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatCEF),
+		uslogs.WithTimestamp(),
+		uslogs.WithDeviceInfo("Acme", "Gate|way", "1.2"),
+		uslogs.WithKeyMapping(map[string]string{"user": "suser", "http.src": "src"}),
+		uslogs.WithMaskedAttributes("password"),
+	)
+	logger := slog.New(handler).With("user", "alice")
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	record := slog.NewRecord(at, slog.LevelWarn, "login failed\nfor alice", 0)
+	record.AddAttrs(
+		slog.String("event_id", "100"),
+		slog.String("password", "secret"),
+		slog.String("query", `a=b\c`),
+		slog.String("bad key", "x|y"),
+		slog.Group("http", slog.String("src", "10.0.0.1"), slog.Int("status", 401)))
+	handleRecord(t, logger.Handler(), record)
+	handleRecord(t, slog.New(handler).Handler(), slog.NewRecord(at, slog.LevelError+4, "no|attrs", 0))
+
+	expected := `CEF:0|Acme|Gate\|way|1.2|100|login failed for alice|6|rt=1735689600000 suser=alice ` +
+		`password=<MASKED> query=a\=b\\c bad_key=x|y src=10.0.0.1 http.status=401` + "\n" +
+		`CEF:0|Acme|Gate\|way|1.2|no\|attrs|no\|attrs|10|rt=1735689600000` + "\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_CEFWithoutTime(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatCEF),
+		uslogs.WithDeviceInfo("Acme", "Gateway", "1.2"),
+		uslogs.WithEventIDKey("sig"),
+	)
+
+	slog.New(handler).Info("started", "sig", 7, "port", 8080)
+	slog.New(handler).Debug("hidden")
+	slog.New(handler).Info("bare")
+
+	expected := "CEF:0|Acme|Gateway|1.2|7|started|3|port=8080\n" +
+		"CEF:0|Acme|Gateway|1.2|bare|bare|3|\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_LEEF(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatLEEF),
+		uslogs.WithTimestamp(),
+		uslogs.WithDeviceInfo("Acme", "Gateway", "1.2"),
+		uslogs.WithKeyMapping(map[string]string{"user": "usrName"}),
+	)
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	record := slog.NewRecord(at, slog.LevelError, "denied", 0)
+	record.AddAttrs(slog.String("event_id", "AUTH_FAIL"), slog.String("user", "alice"),
+		slog.String("note", "a\tb\\c\nd"))
+	handleRecord(t, handler, record)
+
+	expected := "LEEF:2.0|Acme|Gateway|1.2|AUTH_FAIL|x09|sev=8\tdevTime=2025-01-01T00:00:00.000Z\t" +
+		"devTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSXXX\tmsg=denied\tusrName=alice\tnote=a\\tb\\\\c\\nd\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_CEFMasksBeforeEscaping(t *testing.T) {
+	for _, format := range []uslogs.Format{uslogs.FormatCEF, uslogs.FormatLEEF} {
+		var out bytes.Buffer
+		handler := uslogs.NewUnstructuredHandler(
+			uslogs.WithWriter(&out),
+			uslogs.WithFormat(format),
+			uslogs.WithDeviceInfo("Acme", "Gateway", "1.2"),
+			uslogs.WithMaskedPatterns(
+				uslogs.MaskPattern{Start: "token=", Delimiters: []byte{' ', '\n'}},
+				uslogs.MaskPattern{Start: "card=", Delimiters: []byte{' ', '\n'}}),
+		)
+
+		slog.New(handler).Info("auth token=abc=def\\x ok", "card", "4111111111111111", "query", "token=a=b")
+
+		var expected string
+		if format == uslogs.FormatCEF {
+			expected = `CEF:0|Acme|Gateway|1.2|auth token=********* ok|auth token=********* ok|3|` +
+				`card=**************** query=token\=***` + "\n"
+		} else {
+			expected = "LEEF:2.0|Acme|Gateway|1.2|auth token=********* ok|x09|sev=3\t" +
+				"msg=auth token=********* ok\tcard=****************\tquery=token=***\n"
+		}
+		if out.String() != expected {
+			t.Errorf("format %d: got %q, expected %q", format, out.String(), expected)
+		}
+	}
+}
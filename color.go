@@ -0,0 +1,73 @@
+package uslogs
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+	ansiBold   = "\x1b[1m"
+)
+
+// ColorMode represents when UnstructuredHandler colors its output with ANSI escape sequences.
+type ColorMode int
+
+const (
+	// ColorNever never colors the output. It is the default.
+	ColorNever ColorMode = iota
+	// ColorAuto colors the output when the writer is a terminal. A non-empty NO_COLOR environment variable
+	// disables colors and a non-empty FORCE_COLOR enables them, whatever the writer.
+	ColorAuto
+	// ColorAlways colors the output whatever the writer and the environment.
+	ColorAlways
+)
+
+//nolint:gochecknoglobals
+var levelColors = map[slog.Level]string{
+	slog.LevelDebug: ansiBlue,
+	slog.LevelInfo:  ansiGreen,
+	slog.LevelWarn:  ansiYellow,
+	slog.LevelError: ansiBold + ansiRed,
+}
+
+// useColor resolves the color mode against the writer and the environment. Colors are only used with FormatText
+// and never with masked patterns, as an escape sequence in the middle of a secret would prevent the Masker from
+// recognizing it.
+func (l *UnstructuredHandler) useColor() bool {
+	if l.colorMode == ColorNever || l.format != FormatText ||
+		(l.partialMasker != nil && len(l.partialMaskPatterns) > 0) {
+		return false
+	}
+	if l.colorMode == ColorAlways {
+		return true
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if os.Getenv("FORCE_COLOR") != "" {
+		return true
+	}
+	return isTerminalWriter(l.writer)
+}
+
+func isTerminalWriter(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	return ok && isTerminal(file.Fd())
+}
+
+// isErrorValue reports whether the value holds an error, whose attribute is highlighted.
+func isErrorValue(value slog.Value) bool {
+	if value.Kind() != slog.KindAny {
+		return false
+	}
+	_, ok := value.Any().(error)
+	return ok
+}
@@ -0,0 +1,73 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestUnstructuredHandler_ColorAlways(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithLevelWidth(5),
+		uslogs.WithColor(uslogs.ColorAlways),
+	))
+
+	logger.Error("query failed", "table", "users", "err", errors.New("timeout"))
+
+	expected := "\x1b[1m\x1b[31mERROR\x1b[0m query failed \x1b[36mtable\x1b[0m=users " +
+		"\x1b[36merr\x1b[0m=\x1b[31mtimeout\x1b[0m\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_ColorAuto(t *testing.T) {
+	tests := []struct {
+		name       string
+		noColor    string
+		forceColor string
+		colored    bool
+	}{
+		{name: "not a terminal", colored: false},
+		{name: "forced", forceColor: "1", colored: true},
+		{name: "disabled", noColor: "1", forceColor: "1", colored: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			t.Setenv("FORCE_COLOR", tt.forceColor)
+			var out bytes.Buffer
+			logger := slog.New(uslogs.NewUnstructuredHandler(
+				uslogs.WithWriter(&out),
+				uslogs.WithTimestamp(),
+				uslogs.WithColor(uslogs.ColorAuto),
+			))
+
+			logger.Info("started")
+
+			if colored := bytes.Contains(out.Bytes(), []byte("\x1b[")); colored != tt.colored {
+				t.Fatalf("got %q, expected colored=%v", out.String(), tt.colored)
+			}
+		})
+	}
+}
+
+func TestUnstructuredHandler_ColorNeverWithMasking(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithColor(uslogs.ColorAlways),
+		uslogs.WithMaskedPatterns(uslogs.MaskPattern{Start: "token=", Delimiters: []byte{' ', '\n'}}),
+	))
+
+	logger.Info("login", "token", "secret")
+
+	if out.String() != "INFO login token=******\n" {
+		t.Fatalf("got %q, expected masked output without colors", out.String())
+	}
+}
@@ -0,0 +1,163 @@
+package uslogs
+
+import (
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const truncationMark = "…"
+
+// Overflow represents what happens to a message longer than the message column.
+type Overflow int
+
+const (
+	// OverflowKeep writes long messages in full and starts the attributes right after them.
+	OverflowKeep Overflow = iota
+	// OverflowTruncate cuts long messages to the column width, ending them with "…".
+	OverflowTruncate
+	// OverflowWrap continues long messages on the following lines, indented to the message column. It breaks
+	// lines at the last space that fits when there is one.
+	OverflowWrap
+)
+
+// appendLevel appends the level name, padded to the level width.
+func (l *UnstructuredHandler) appendLevel(dst []byte, level slog.Level) []byte {
+	name := levelNames[level]
+	if l.colored {
+		dst = append(dst, levelColors[level]...)
+		dst = append(dst, name...)
+		dst = append(dst, ansiReset...)
+	} else {
+		dst = append(dst, name...)
+	}
+	return appendPadding(dst, l.levelWidth-len(name))
+}
+
+// appendMessage appends the message, or only its first line with MultilineBlock, and rewrites its line breaks
+// according to the multi-line policy.
+func (l *UnstructuredHandler) appendMessage(dst []byte, message string, lineStart int, hasAttrs bool) []byte {
+	if l.multiline == MultilineKeep {
+		return l.appendMessageColumn(dst, message, lineStart, hasAttrs)
+	}
+	if l.multiline == MultilineBlock {
+		message, _, _ = strings.Cut(message, "\n")
+	}
+	if strings.ContainsAny(message, "\r\n") {
+		// Line breaks are rewritten before the message is fitted to its column, as wrapping adds its own.
+		message = string(l.foldLines([]byte(message), 0))
+	}
+	return l.appendMessageColumn(dst, message, lineStart, hasAttrs)
+}
+
+// appendMessageColumn appends the message. With a message width, it pads the message to the column when
+// attributes follow, and truncates or wraps it when it is longer. lineStart is the position of the line in dst,
+// used to indent wrapped lines.
+func (l *UnstructuredHandler) appendMessageColumn(dst []byte, message string, lineStart int, hasAttrs bool) []byte {
+	if l.messageWidth <= 0 {
+		return append(dst, message...)
+	}
+	length := utf8.RuneCountInString(message)
+	switch {
+	case length <= l.messageWidth || l.overflow == OverflowKeep:
+		dst = append(dst, message...)
+	case l.overflow == OverflowTruncate:
+		cut := runeOffset(message, l.messageWidth-1)
+		dst = append(dst, message[:cut]...)
+		dst = append(dst, truncationMark...)
+		length = l.messageWidth
+	default:
+		return l.appendWrappedMessage(dst, message, lineStart, hasAttrs)
+	}
+	if hasAttrs {
+		dst = appendPadding(dst, l.messageWidth-length)
+	}
+	return dst
+}
+
+// appendWrappedMessage splits the message in chunks of at most the message width and writes each one on its own
+// line. Masking runs on the whole message first, as a secret split across lines would not be recognized later.
+func (l *UnstructuredHandler) appendWrappedMessage(dst []byte, message string, lineStart int, hasAttrs bool) []byte {
+	indent := visibleWidth(dst[lineStart:])
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	scratch := append((*buf)[:0], message...)
+	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 {
+		scratch = l.partialMasker.Mask(scratch, l.partialMaskPatterns)
+	}
+	text := scratch
+	for first := true; ; first = false {
+		if !first {
+			dst = append(dst, '\n')
+			dst = appendPadding(dst, indent)
+		}
+		cut := runeOffsetBytes(text, l.messageWidth)
+		if cut == len(text) {
+			dst = append(dst, text...)
+			if hasAttrs {
+				dst = appendPadding(dst, l.messageWidth-utf8.RuneCount(text))
+			}
+			break
+		}
+		end, next := cut, cut
+		for idx := cut; idx > 0; idx-- {
+			if text[idx] == ' ' {
+				end, next = idx, idx+1
+				break
+			}
+		}
+		dst = append(dst, text[:end]...)
+		text = text[next:]
+	}
+	*buf = scratch[:0]
+	logutils.PutPool(logutils.SimplePool, buf)
+	return dst
+}
+
+// visibleWidth returns the number of runes of the text, leaving out the ANSI CSI sequences that color it.
+func visibleWidth(text []byte) int {
+	width := 0
+	for idx := 0; idx < len(text); {
+		if text[idx] == '\x1b' && idx+1 < len(text) && text[idx+1] == '[' {
+			// A CSI sequence ends with its final byte, in the range '@' to '~'.
+			idx += 2
+			for idx < len(text) && (text[idx] < '@' || text[idx] > '~') {
+				idx++
+			}
+			idx++
+			continue
+		}
+		_, size := utf8.DecodeRune(text[idx:])
+		idx += size
+		width++
+	}
+	return width
+}
+
+// runeOffset returns the byte offset of the rune at the given index, or the length of the text if it is shorter.
+func runeOffset(text string, runes int) int {
+	for offset := range text {
+		if runes == 0 {
+			return offset
+		}
+		runes--
+	}
+	return len(text)
+}
+
+func runeOffsetBytes(text []byte, runes int) int {
+	offset := 0
+	for ; runes > 0 && offset < len(text); runes-- {
+		_, size := utf8.DecodeRune(text[offset:])
+		offset += size
+	}
+	return offset
+}
+
+func appendPadding(dst []byte, width int) []byte {
+	for range width {
+		dst = append(dst, ' ')
+	}
+	return dst
+}
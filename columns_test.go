@@ -0,0 +1,100 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestUnstructuredHandler_Columns(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithLevel(slog.LevelDebug),
+		uslogs.WithLevelWidth(5),
+		uslogs.WithMessageWidth(10, uslogs.OverflowKeep),
+	))
+
+	logger.Info("started", "port", 8080)
+	logger.Debug("connecting", "host", "db")
+	logger.Warn("slow")
+	logger.Error("query failed", "table", "users")
+
+	expected := "INFO  started    port=8080\n" +
+		"DEBUG connecting host=db\n" +
+		"WARN  slow\n" +
+		"ERROR query failed table=users\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_ColumnsTruncate(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithMessageWidth(8, uslogs.OverflowTruncate),
+	))
+
+	logger.Info("connection refusée", "attempt", 1)
+
+	if out.String() != "INFO connect… attempt=1\n" {
+		t.Fatalf("got %q, expected a truncated message", out.String())
+	}
+}
+
+func TestUnstructuredHandler_ColumnsWrap(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithLevelWidth(5),
+		uslogs.WithMessageWidth(12, uslogs.OverflowWrap),
+	))
+
+	logger.Info("the quick brown fox jumps over", "n", 1)
+	logger.Info("abcdefghijklmnopq")
+
+	expected := "INFO  the quick\n" +
+		"      brown fox\n" +
+		"      jumps over   n=1\n" +
+		"INFO  abcdefghijkl\n" +
+		"      mnopq\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_ColumnsWrapWithColor(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithColor(uslogs.ColorAlways),
+		uslogs.WithLevelWidth(5),
+		uslogs.WithMessageWidth(12, uslogs.OverflowWrap),
+	))
+
+	logger.Info("the quick brown fox")
+
+	expected := "\x1b[32mINFO\x1b[0m  the quick\n" +
+		"      brown fox\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_ColumnsWrapMasksBeforeSplitting(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithMaskedPatterns(uslogs.MaskPattern{Start: "token=", Delimiters: []byte{' '}}),
+		uslogs.WithMessageWidth(12, uslogs.OverflowWrap),
+	))
+
+	logger.Info("login token=abcdefghijkl done")
+
+	if bytes.Contains(out.Bytes(), []byte("abcd")) || bytes.Contains(out.Bytes(), []byte("ijkl")) {
+		t.Fatalf("got %q, expected the secret to be masked before wrapping", out.String())
+	}
+}
@@ -0,0 +1,38 @@
+package uslogs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// ContextExtractor appends to dst the attributes carried by the given context, such as trace, request or
+// tenant ids, and returns the extended slice. It must not retain dst.
+type ContextExtractor = func(ctx context.Context, dst []slog.Attr) []slog.Attr
+
+type contextAttrsKey struct{}
+
+//nolint:gochecknoglobals
+var contextAttrsPool = sync.Pool{
+	New: func() any {
+		attrs := make([]slog.Attr, 0, 8) //nolint:mnd
+		return &attrs
+	},
+}
+
+// ContextWithAttrs returns a copy of the given context carrying the given attributes after the ones it already
+// carries. They are appended to every line logged with that context by handlers configured with
+// WithContextExtractors(AppendContextAttrs).
+func ContextWithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent, _ := ctx.Value(contextAttrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(parent)+len(attrs))
+	merged = append(merged, parent...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, contextAttrsKey{}, merged)
+}
+
+// AppendContextAttrs is a ContextExtractor that appends the attributes stored with ContextWithAttrs.
+func AppendContextAttrs(ctx context.Context, dst []slog.Attr) []slog.Attr {
+	attrs, _ := ctx.Value(contextAttrsKey{}).([]slog.Attr)
+	return append(dst, attrs...)
+}
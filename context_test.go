@@ -0,0 +1,55 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+type tenantKey struct{}
+
+func extractTenant(ctx context.Context, dst []slog.Attr) []slog.Attr {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		dst = append(dst, slog.String("tenant", tenant))
+	}
+	return dst
+}
+
+func TestUnstructuredHandler_ContextAttrs(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithContextExtractors(uslogs.AppendContextAttrs, extractTenant),
+	)).With("service", "api").WithGroup("http")
+
+	ctx := uslogs.ContextWithAttrs(context.Background(), slog.String("request_id", "r-1"))
+	ctx = uslogs.ContextWithAttrs(ctx, slog.String("user", "u-2"))
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+	logger.InfoContext(ctx, "served", "status", 200)
+	logger.Info("no context")
+
+	expected := "INFO served service=api request_id=r-1 user=u-2 tenant=acme http.status=200\n" +
+		"INFO no context service=api\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_ContextAttrsMasked(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithMaskedAttributes("token"),
+		uslogs.WithContextExtractors(uslogs.AppendContextAttrs),
+	))
+
+	ctx := uslogs.ContextWithAttrs(context.Background(), slog.String("token", "secret"))
+	logger.InfoContext(ctx, "authenticated")
+
+	if out.String() != "INFO authenticated token=<MASKED>\n" {
+		t.Fatalf("got %q, expected the context attribute to be masked", out.String())
+	}
+}
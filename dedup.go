@@ -0,0 +1,193 @@
+package uslogs
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxDedupEntries caps the number of distinct lines a Deduplicator tracks at once. Lines seen while it is
+// reached are written without being tracked.
+const maxDedupEntries = 4096
+
+// Deduplicator collapses identical lines into the first line plus a "last message repeated N times" summary, the
+// way syslogd does.
+//
+// Lines are compared by a hash of their formatted bytes after the timestamp, so no copy of them is kept. With a
+// window, every line seen within the window of its first occurrence is collapsed, even when other lines come in
+// between; without one, only consecutive lines are. The summary is written with the level of the repeated line
+// once its window elapses, even if nothing else is logged, before the next different line without a window, or on
+// Flush. Handlers sharing a Deduplicator compare their lines with each other.
+type Deduplicator struct {
+	entries map[uint64]dedupEntry
+	timer   *time.Timer
+	expiry  []dedupExpiry
+	seed    maphash.Seed
+	window  time.Duration
+	mu      sync.Mutex
+}
+
+// dedupEntry tracks a line by its hash.
+type dedupEntry struct {
+	firstSeen time.Time
+	handler   *UnstructuredHandler
+	repeated  uint64
+	level     slog.Level
+}
+
+// dedupExpiry queues the hash of a line in the order its window started. An item is stale when the entry was
+// restarted or removed since.
+type dedupExpiry struct {
+	firstSeen time.Time
+	hash      uint64
+}
+
+// NewDeduplicator creates a new Deduplicator instance. A positive window collapses the lines seen again within
+// that time, and writes the summary of a line still repeated once it lasts that long, so repeated lines are
+// reported at least that often. A zero window only collapses consecutive lines and reports them when they end.
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	//nolint:exhaustruct
+	return &Deduplicator{
+		entries: make(map[uint64]dedupEntry),
+		seed:    maphash.MakeSeed(),
+		window:  window,
+	}
+}
+
+// Flush writes the summary of every repeated line, in the order they were first seen.
+func (d *Deduplicator) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	hashes := make([]uint64, 0, len(d.entries))
+	for hash, entry := range d.entries {
+		if entry.repeated > 0 {
+			hashes = append(hashes, hash)
+		}
+	}
+	slices.SortFunc(hashes, func(a, b uint64) int {
+		return d.entries[a].firstSeen.Compare(d.entries[b].firstSeen)
+	})
+	now := time.Now()
+	var err error
+	for _, hash := range hashes {
+		entry := d.entries[hash]
+		err = errors.Join(err, writeRepeated(now, entry))
+		entry.repeated = 0
+		d.entries[hash] = entry
+	}
+	return err
+}
+
+// observe reports whether the given line must be written, after writing the summaries of the lines whose window
+// elapsed.
+func (d *Deduplicator) observe(
+	handler *UnstructuredHandler, record slog.Record, line []byte, timeStart, timeEnd int,
+) (bool, error) {
+	now := record.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	var hasher maphash.Hash
+	hasher.SetSeed(d.seed)
+	_, _ = hasher.Write(line[:timeStart])
+	_, _ = hasher.Write(line[timeEnd:])
+	hash := hasher.Sum64()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.entries[hash]
+	if ok && (d.window <= 0 || now.Sub(entry.firstSeen) < d.window) {
+		entry.repeated++
+		d.entries[hash] = entry
+		d.arm()
+		return false, nil
+	}
+	if ok && d.window > 0 && entry.repeated > 0 {
+		// The window elapsed while the line kept repeating: it is reported and a new window starts.
+		entry.repeated++
+		err := writeRepeated(now, entry)
+		d.track(hash, dedupEntry{firstSeen: now, handler: entry.handler, repeated: 0, level: entry.level})
+		return false, errors.Join(err, d.expire(now))
+	}
+	err := d.expire(now)
+	if len(d.entries) < maxDedupEntries {
+		d.track(hash, dedupEntry{firstSeen: now, handler: handler, repeated: 0, level: record.Level})
+	}
+	return true, err
+}
+
+// expire writes the summaries of the lines whose window elapsed, or of every line without a window, and stops
+// tracking them.
+func (d *Deduplicator) expire(now time.Time) error {
+	var err error
+	if d.window <= 0 {
+		for hash, entry := range d.entries {
+			err = errors.Join(err, writeRepeated(now, entry))
+			delete(d.entries, hash)
+		}
+		return err
+	}
+	head := 0
+	for ; head < len(d.expiry) && now.Sub(d.expiry[head].firstSeen) >= d.window; head++ {
+		item := d.expiry[head]
+		entry, ok := d.entries[item.hash]
+		if !ok || !entry.firstSeen.Equal(item.firstSeen) {
+			continue
+		}
+		err = errors.Join(err, writeRepeated(now, entry))
+		delete(d.entries, item.hash)
+	}
+	if head > 0 {
+		d.expiry = append(d.expiry[:0], d.expiry[head:]...)
+	}
+	return err
+}
+
+// arm starts the timer that reports the repeated lines once their window elapses, if no line comes in to do it.
+func (d *Deduplicator) arm() {
+	if d.window > 0 && d.timer == nil {
+		d.timer = time.AfterFunc(d.window, d.expireIdle)
+	}
+}
+
+// expireIdle writes the summaries of the lines whose window elapsed since the last line, and waits for the next
+// window to elapse while some lines are still repeated.
+func (d *Deduplicator) expireIdle() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timer = nil
+	now := time.Now()
+	if err := d.expire(now); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "deduplicator error: %v\n", err)
+	}
+	for _, entry := range d.entries {
+		if entry.repeated > 0 {
+			wait := d.window
+			if len(d.expiry) > 0 {
+				wait = min(max(d.expiry[0].firstSeen.Add(d.window).Sub(now), time.Millisecond), d.window)
+			}
+			d.timer = time.AfterFunc(wait, d.expireIdle)
+			return
+		}
+	}
+}
+
+func (d *Deduplicator) track(hash uint64, entry dedupEntry) {
+	d.entries[hash] = entry
+	if d.window > 0 {
+		d.expiry = append(d.expiry, dedupExpiry{firstSeen: entry.firstSeen, hash: hash})
+	}
+}
+
+func writeRepeated(now time.Time, entry dedupEntry) error {
+	if entry.repeated == 0 {
+		return nil
+	}
+	message := "last message repeated " + strconv.FormatUint(entry.repeated, decimalBase) + " times"
+	return entry.handler.writeSummary(now, entry.level, message)
+}
@@ -0,0 +1,107 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestDeduplicator_CollapsesConsecutiveLines(t *testing.T) {
+	var out bytes.Buffer
+	deduplicator := uslogs.NewDeduplicator(0)
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithTimestamp(),
+		uslogs.WithDeduplicator(deduplicator))
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for second := range 4 {
+		handleAt(t, handler, start.Add(time.Duration(second)*time.Second), slog.LevelWarn, "retrying")
+	}
+	handleAt(t, handler, start.Add(5*time.Second), slog.LevelInfo, "connected")
+	handleAt(t, handler, start.Add(6*time.Second), slog.LevelInfo, "connected")
+	if err := deduplicator.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "2025-01-01T00:00:00Z WARN retrying\n" +
+		"2025-01-01T00:00:05Z WARN last message repeated 3 times\n" +
+		"2025-01-01T00:00:05Z INFO connected\n"
+	if out.String()[:len(expected)] != expected {
+		t.Fatalf("got %q, expected it to start with %q", out.String(), expected)
+	}
+	if !bytes.HasSuffix(out.Bytes(), []byte(" INFO last message repeated 1 times\n")) {
+		t.Fatalf("got %q, expected the flushed summary", out.String())
+	}
+}
+
+func TestDeduplicator_ComparesAttributes(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out),
+		uslogs.WithDeduplicator(uslogs.NewDeduplicator(0))))
+
+	logger.Info("retrying", "attempt", 1)
+	logger.Info("retrying", "attempt", 2)
+	logger.Info("retrying", "attempt", 2)
+	logger.Info("done")
+
+	expected := "INFO retrying attempt=1\nINFO retrying attempt=2\nINFO last message repeated 1 times\nINFO done\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestDeduplicator_Window(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out),
+		uslogs.WithDeduplicator(uslogs.NewDeduplicator(10*time.Second)))
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for second := range 25 {
+		handleAt(t, handler, start.Add(time.Duration(second)*time.Second), slog.LevelInfo, "polling")
+	}
+
+	expected := "INFO polling\nINFO last message repeated 10 times\nINFO last message repeated 10 times\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestDeduplicator_CollapsesInterleavedLines(t *testing.T) {
+	var out bytes.Buffer
+	deduplicator := uslogs.NewDeduplicator(10 * time.Second)
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithDeduplicator(deduplicator))
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for second, msg := range []string{"ping a", "ping b", "ping a", "ping b", "ping a"} {
+		handleAt(t, handler, start.Add(time.Duration(second)*time.Second), slog.LevelInfo, msg)
+	}
+	handleAt(t, handler, start.Add(20*time.Second), slog.LevelWarn, "idle")
+	handleAt(t, handler, start.Add(21*time.Second), slog.LevelInfo, "ping a")
+
+	expected := "INFO ping a\nINFO ping b\n" +
+		"INFO last message repeated 2 times\nINFO last message repeated 1 times\n" +
+		"WARN idle\nINFO ping a\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestDeduplicator_WritesSummaryWhenIdle(t *testing.T) {
+	//nolint:exhaustruct
+	out := &lockedBuffer{}
+	logger := slog.New(uslogs.NewUnstructuredHandler(uslogs.WithWriter(out),
+		uslogs.WithDeduplicator(uslogs.NewDeduplicator(20*time.Millisecond))))
+
+	logger.Info("polling")
+	logger.Info("polling")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(out.Lines()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if lines := out.Lines(); len(lines) != 2 || lines[1] != "INFO last message repeated 1 times" {
+		t.Fatalf("got %q, expected the summary once the window elapsed", lines)
+	}
+}
@@ -0,0 +1,81 @@
+package uslogs
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// Sink is a destination of a FanoutWriter with its own routing rules.
+type Sink struct {
+	// Writer receives the lines routed to this sink. If it implements LevelWriter, it also receives the level.
+	Writer io.Writer
+	// Filter optionally rejects lines that passed the level check.
+	Filter func(level slog.Level, line []byte) bool
+	// MinLevel is the lowest level written to this sink.
+	MinLevel slog.Level
+}
+
+// FanoutWriter dispatches every line to several sinks, each with its own minimum level and filter. The line is
+// formatted once by the handler and the same bytes are written to every matching sink. A failing sink does not
+// prevent the others from receiving the line.
+type FanoutWriter struct {
+	sinks    []Sink
+	minLevel slog.Level
+}
+
+// NewFanoutWriter creates a new FanoutWriter instance with the given sinks.
+func NewFanoutWriter(sinks ...Sink) *FanoutWriter {
+	fanoutWriter := &FanoutWriter{
+		sinks:    sinks,
+		minLevel: slog.LevelInfo,
+	}
+	for idx, sink := range sinks {
+		if idx == 0 || sink.MinLevel < fanoutWriter.minLevel {
+			fanoutWriter.minLevel = sink.MinLevel
+		}
+	}
+	return fanoutWriter
+}
+
+// MinLevel returns the lowest level accepted by any sink.
+func (f *FanoutWriter) MinLevel() slog.Level {
+	return f.minLevel
+}
+
+// Write dispatches the given line as if it had been logged at slog.LevelInfo.
+func (f *FanoutWriter) Write(input []byte) (int, error) {
+	return f.WriteLevel(slog.LevelInfo, input)
+}
+
+// WriteLevel dispatches the given line to every sink accepting the level. Errors from the sinks are joined.
+func (f *FanoutWriter) WriteLevel(level slog.Level, input []byte) (int, error) {
+	var err error
+	for idx := range f.sinks {
+		sink := &f.sinks[idx]
+		if level < sink.MinLevel || (sink.Filter != nil && !sink.Filter(level, input)) {
+			continue
+		}
+		var sinkErr error
+		if levelWriter, ok := sink.Writer.(LevelWriter); ok {
+			_, sinkErr = levelWriter.WriteLevel(level, input)
+		} else {
+			_, sinkErr = sink.Writer.Write(input)
+		}
+		if sinkErr != nil {
+			err = errors.Join(err, sinkErr)
+		}
+	}
+	return len(input), err
+}
+
+// Close closes every sink writer implementing io.Closer and joins their errors.
+func (f *FanoutWriter) Close() error {
+	var err error
+	for _, sink := range f.sinks {
+		if closer, ok := sink.Writer.(io.Closer); ok {
+			err = errors.Join(err, closer.Close())
+		}
+	}
+	return err
+}
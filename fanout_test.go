@@ -0,0 +1,78 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestWithSinks_RoutesByLevel(t *testing.T) {
+	var stdout, stderr, file bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithSinks(
+		uslogs.Sink{Writer: &stdout, MinLevel: slog.LevelInfo},
+		uslogs.Sink{Writer: &stderr, MinLevel: slog.LevelError},
+		uslogs.Sink{Writer: &file, MinLevel: slog.LevelDebug},
+	))
+	logger := slog.New(handler)
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Error("error")
+
+	if stdout.String() != "INFO info\nERROR error\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if stderr.String() != "ERROR error\n" {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	if file.String() != "DEBUG debug\nINFO info\nERROR error\n" {
+		t.Errorf("file = %q", file.String())
+	}
+}
+
+func TestWithSinks_FilterAndSharedState(t *testing.T) {
+	var audit, all bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithSinks(
+		uslogs.Sink{
+			Writer:   &audit,
+			MinLevel: slog.LevelInfo,
+			Filter: func(_ slog.Level, line []byte) bool {
+				return bytes.Contains(line, []byte("audit=true"))
+			},
+		},
+		uslogs.Sink{Writer: &all, MinLevel: slog.LevelInfo},
+	))
+	logger := slog.New(handler).With("svc", "api").WithGroup("req")
+
+	logger.Info("login", "audit", true)
+	logger.Info("ping")
+
+	if audit.String() != "INFO login svc=api req.audit=true\n" {
+		t.Errorf("audit = %q", audit.String())
+	}
+	if all.String() != "INFO login svc=api req.audit=true\nINFO ping svc=api\n" {
+		t.Errorf("all = %q", all.String())
+	}
+}
+
+func TestFanoutWriter_FailingSinkDoesNotAffectOthers(t *testing.T) {
+	var healthy bytes.Buffer
+	w := uslogs.NewFanoutWriter(
+		uslogs.Sink{Writer: failingWriter{}, MinLevel: slog.LevelInfo},
+		uslogs.Sink{Writer: &healthy, MinLevel: slog.LevelInfo},
+	)
+
+	n, err := w.WriteLevel(slog.LevelWarn, []byte("line\n"))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("got error %v, expected the failing sink error", err)
+	}
+	if n != 5 || healthy.String() != "line\n" {
+		t.Fatalf("got %d bytes and %q in the healthy sink, expected the line", n, healthy.String())
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
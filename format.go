@@ -0,0 +1,21 @@
+package uslogs
+
+// Format represents the encoding of the lines written by UnstructuredHandler.
+type Format int
+
+const (
+	// FormatText writes the level, the message and space-separated key=value attributes. It is the default.
+	FormatText Format = iota
+	// FormatLogfmt writes lines following the logfmt conventions: time, level and msg are key=value pairs,
+	// values are quoted and escaped when needed, and pairs are always separated by a single space.
+	FormatLogfmt
+	// FormatJSON writes every line as a JSON object with the time, level and msg members followed by the
+	// attributes. Groups become nested objects.
+	FormatJSON
+	// FormatCEF writes every line as an ArcSight Common Event Format event. The signature id is the value of
+	// the event id attribute or the message, the name is the message, and attributes become extension pairs.
+	FormatCEF
+	// FormatLEEF writes every line as an IBM QRadar LEEF 2.0 event with tab-separated attributes. The event id
+	// is the value of the event id attribute or the message.
+	FormatLEEF
+)
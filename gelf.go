@@ -0,0 +1,395 @@
+package uslogs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const (
+	gelfVersion             = "1.1"
+	gelfNetworkUDP          = "udp"
+	gelfChunkHeaderSize     = 12
+	gelfMaxChunks           = 128
+	defaultGELFChunkSize    = 1420
+	minGELFChunkSize        = gelfChunkHeaderSize + 1
+	defaultGELFBackoff      = 100 * time.Millisecond
+	maxGELFBackoff          = 30 * time.Second
+	gelfDialTimeout         = 5 * time.Second
+	gelfMicrosecondsDivisor = 100000
+)
+
+var (
+	// ErrGELFUnavailable is returned by GELFWriter while it waits to reconnect to the remote server.
+	ErrGELFUnavailable = errors.New("gelf server unavailable")
+	// ErrGELFTooLarge is returned by GELFWriter for UDP messages that do not fit in 128 chunks.
+	ErrGELFTooLarge = errors.New("gelf message too large")
+)
+
+// GELFCompression represents the compression of GELF messages sent over UDP.
+type GELFCompression int
+
+const (
+	// GELFCompressNone sends messages uncompressed. It is the default.
+	GELFCompressNone GELFCompression = iota
+	// GELFCompressGzip compresses messages with gzip.
+	GELFCompressGzip
+	// GELFCompressZlib compresses messages with zlib.
+	GELFCompressZlib
+)
+
+// GELFOption represents a function that configures a GELFWriter.
+type GELFOption = func(w *GELFWriter)
+
+// WithGELFHost sets the host field of every message. Defaults to os.Hostname.
+func WithGELFHost(host string) GELFOption {
+	return func(gelfWriter *GELFWriter) {
+		gelfWriter.host = host
+	}
+}
+
+// WithGELFCompression sets the compression of messages sent over UDP. Graylog does not accept compressed
+// messages over TCP, so it is ignored there.
+func WithGELFCompression(compression GELFCompression) GELFOption {
+	return func(gelfWriter *GELFWriter) {
+		gelfWriter.compression = compression
+	}
+}
+
+// WithGELFChunkSize sets the maximum size of the UDP datagrams, chunk header included. Larger messages are
+// split in chunks. Defaults to 1420 bytes, which fits in the usual MTU of WAN links.
+func WithGELFChunkSize(size int) GELFOption {
+	return func(gelfWriter *GELFWriter) {
+		gelfWriter.chunkSize = max(size, minGELFChunkSize)
+	}
+}
+
+// WithGELFDefaultLevel sets the level used for lines written through Write instead of WriteRecord. Defaults to
+// slog.LevelInfo.
+func WithGELFDefaultLevel(level slog.Level) GELFOption {
+	return func(gelfWriter *GELFWriter) {
+		gelfWriter.defaultLevel = level
+	}
+}
+
+// GELFWriter is a writer that sends every line to Graylog as a GELF 1.1 message.
+//
+// When used as the handler writer, the first line of the record message becomes the short_message and the
+// whole message the full_message when it spans several lines, the record level is mapped to a syslog
+// severity and attributes become additional fields prefixed by an underscore. Masked patterns apply to the
+// message and to every field as it is written on a text line, before they are encoded. Lines written through
+// Write are sent as the short_message with the default level.
+//
+// It supports the "udp" network, where messages larger than the chunk size are chunked and can be compressed,
+// and the "tcp" network, where messages are terminated by a null byte. When the TCP connection fails, the
+// writer reconnects with an exponential backoff and messages written while waiting are dropped with
+// ErrGELFUnavailable.
+type GELFWriter struct {
+	conn         net.Conn
+	gzipWriter   *gzip.Writer
+	zlibWriter   *zlib.Writer
+	nextDial     time.Time
+	network      string
+	address      string
+	host         string
+	compressed   bytes.Buffer
+	chunk        []byte
+	backoff      time.Duration
+	chunkSize    int
+	compression  GELFCompression
+	defaultLevel slog.Level
+	mu           sync.Mutex
+	closed       bool
+}
+
+// NewGELFWriter creates a new GELFWriter instance connected to the given network and address.
+func NewGELFWriter(network, address string, opts ...GELFOption) (*GELFWriter, error) {
+	hostname, _ := os.Hostname()
+	//nolint:exhaustruct
+	gelfWriter := &GELFWriter{
+		network:      network,
+		address:      address,
+		host:         hostname,
+		chunkSize:    defaultGELFChunkSize,
+		defaultLevel: slog.LevelInfo,
+		backoff:      defaultGELFBackoff,
+	}
+	for _, opt := range opts {
+		opt(gelfWriter)
+	}
+	if err := gelfWriter.dial(); err != nil {
+		return nil, err
+	}
+	return gelfWriter, nil
+}
+
+// Write sends the given line as the short_message of a message with the default level.
+func (w *GELFWriter) Write(input []byte) (int, error) {
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	message := appendGELFHeader((*buf)[:0], w.host, w.defaultLevel, time.Now(), bytes.TrimRight(input, "\n"))
+	message = append(message, '}')
+	err := w.send(message)
+	*buf = message
+	logutils.PutPool(logutils.SimplePool, buf)
+	if err != nil {
+		return 0, err
+	}
+	return len(input), nil
+}
+
+// WriteRecord sends the given entry with its attributes as additional fields.
+func (w *GELFWriter) WriteRecord(entry *Entry) error {
+	textBuf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	text := append((*textBuf)[:0], entry.Message...)
+	text = entry.Mask(append(text, ' ', '\n'))[:len(entry.Message)]
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	message := appendGELFHeader((*buf)[:0], w.host, entry.Level, entry.Time, text)
+	if entry.PC != 0 {
+		source := sourceOf(entry.PC)
+		if sep := strings.LastIndexByte(source, ':'); sep >= 0 {
+			message = append(message, `,"_file":`...)
+			message = appendJSONString(message, source[:sep])
+			message = append(message, `,"_line":`...)
+			message = append(message, source[sep+1:]...)
+		}
+	}
+	for _, attr := range entry.Attrs {
+		message = append(message, ',', '"', '_')
+		message = appendGELFFieldName(message, attr.Key)
+		message = append(message, '"', ':')
+		text = entry.appendMaskedValue(text[:0], attr)
+		masked := len(text)
+		if isGELFNumber(attr.Value) {
+			// Numbers stay numbers unless masking changed them.
+			if text = logutils.AppendValue(text, attr.Value); bytes.Equal(text[:masked], text[masked:]) {
+				message = appendGELFValue(message, attr.Value)
+				continue
+			}
+		}
+		message = appendJSONString(message, text[:masked])
+	}
+	message = append(message, '}')
+	*textBuf = text
+	logutils.PutPool(logutils.SimplePool, textBuf)
+	err := w.send(message)
+	*buf = message
+	logutils.PutPool(logutils.SimplePool, buf)
+	return err
+}
+
+// Close closes the connection to the GELF server. Later writes fail with net.ErrClosed.
+func (w *GELFWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err //nolint:wrapcheck
+}
+
+// appendGELFHeader appends the opening of the message with the standard fields, leaving the object open for
+// the additional fields.
+func appendGELFHeader[T string | []byte](dst []byte, host string, level slog.Level, now time.Time, message T) []byte {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	dst = append(dst, `{"version":"`+gelfVersion+`","host":`...)
+	dst = appendJSONString(dst, host)
+	dst = append(dst, `,"short_message":`...)
+	end := 0
+	for end < len(message) && message[end] != '\n' {
+		end++
+	}
+	dst = appendJSONString(dst, message[:end])
+	if end < len(message) {
+		dst = append(dst, `,"full_message":`...)
+		dst = appendJSONString(dst, message)
+	}
+	dst = append(dst, `,"timestamp":`...)
+	dst = strconv.AppendInt(dst, now.Unix(), decimalBase)
+	dst = append(dst, '.')
+	micros := now.Nanosecond() / int(time.Microsecond)
+	for divisor := gelfMicrosecondsDivisor; divisor > 0; divisor /= decimalBase {
+		dst = append(dst, byte('0'+micros/divisor%decimalBase))
+	}
+	dst = append(dst, `,"level":`...)
+	return strconv.AppendInt(dst, int64(syslogSeverity(level)), decimalBase)
+}
+
+func (w *GELFWriter) send(message []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return net.ErrClosed
+	}
+	if w.network == gelfNetworkUDP {
+		return w.sendUDP(message)
+	}
+	if w.conn == nil {
+		if time.Now().Before(w.nextDial) {
+			return ErrGELFUnavailable
+		}
+		if err := w.dial(); err != nil {
+			return w.fail(err)
+		}
+	}
+	// The message is written with its terminating null byte in a single call, as a frame must not be split
+	// between two connections.
+	message = append(message, 0)
+	if _, err := w.conn.Write(message); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return w.fail(err)
+	}
+	w.backoff = defaultGELFBackoff
+	return nil
+}
+
+// sendUDP compresses the message if needed and sends it in a single datagram when it fits, or in chunks
+// sharing a random message id otherwise.
+func (w *GELFWriter) sendUDP(message []byte) error {
+	payload, err := w.compress(message)
+	if err != nil {
+		return err
+	}
+	if len(payload) <= w.chunkSize {
+		_, err = w.conn.Write(payload)
+		return err //nolint:wrapcheck
+	}
+	dataSize := w.chunkSize - gelfChunkHeaderSize
+	count := (len(payload) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return ErrGELFTooLarge
+	}
+	var messageID [8]byte
+	binary.BigEndian.PutUint64(messageID[:], rand.Uint64()) //nolint:gosec
+	for seq := range count {
+		data := payload[seq*dataSize : min((seq+1)*dataSize, len(payload))]
+		w.chunk = append(w.chunk[:0], 0x1e, 0x0f) //nolint:mnd
+		w.chunk = append(w.chunk, messageID[:]...)
+		w.chunk = append(w.chunk, byte(seq), byte(count))
+		w.chunk = append(w.chunk, data...)
+		if _, err = w.conn.Write(w.chunk); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+	return nil
+}
+
+func (w *GELFWriter) compress(message []byte) ([]byte, error) {
+	var compressor interface {
+		io.Writer
+		Close() error
+		Reset(writer io.Writer)
+	}
+	switch w.compression {
+	case GELFCompressGzip:
+		if w.gzipWriter == nil {
+			w.gzipWriter = gzip.NewWriter(nil)
+		}
+		compressor = w.gzipWriter
+	case GELFCompressZlib:
+		if w.zlibWriter == nil {
+			w.zlibWriter = zlib.NewWriter(nil)
+		}
+		compressor = w.zlibWriter
+	default:
+		return message, nil
+	}
+	w.compressed.Reset()
+	compressor.Reset(&w.compressed)
+	if _, err := compressor.Write(message); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return w.compressed.Bytes(), nil
+}
+
+func (w *GELFWriter) fail(err error) error {
+	w.nextDial = time.Now().Add(w.backoff)
+	w.backoff = min(w.backoff*2, maxGELFBackoff) //nolint:mnd
+	return err
+}
+
+func (w *GELFWriter) dial() error {
+	//nolint:exhaustruct
+	dialer := &net.Dialer{Timeout: gelfDialTimeout}
+	conn, err := dialer.Dial(w.network, w.address)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	w.conn = conn
+	return nil
+}
+
+// appendGELFFieldName appends the given key as a valid additional field name: letters, digits, underscores,
+// dashes and dots, where any other character is replaced by an underscore. As "_id" is reserved, the "id" key
+// is written as "_id" too, giving the "__id" field.
+func appendGELFFieldName(dst []byte, key string) []byte {
+	if key == "id" || key == "" {
+		dst = append(dst, '_')
+	}
+	for idx := range len(key) {
+		char := key[idx]
+		if (char < 'a' || char > 'z') && (char < 'A' || char > 'Z') && (char < '0' || char > '9') &&
+			char != '_' && char != '-' && char != '.' {
+			char = '_'
+		}
+		dst = append(dst, char)
+	}
+	return dst
+}
+
+// isGELFNumber reports whether the value is written as a JSON number.
+func isGELFNumber(value slog.Value) bool {
+	//nolint:exhaustive
+	switch value.Kind() {
+	case slog.KindInt64, slog.KindUint64:
+		return true
+	case slog.KindFloat64:
+		return !math.IsNaN(value.Float64()) && !math.IsInf(value.Float64(), 0)
+	}
+	return false
+}
+
+// appendGELFValue appends numbers as JSON numbers and any other value as a string, the only two types GELF
+// allows in additional fields.
+func appendGELFValue(dst []byte, value slog.Value) []byte {
+	//nolint:exhaustive
+	switch value.Kind() {
+	case slog.KindInt64, slog.KindUint64:
+		return appendJSONValue(dst, value)
+	case slog.KindFloat64:
+		if math.IsNaN(value.Float64()) || math.IsInf(value.Float64(), 0) {
+			break
+		}
+		return appendJSONValue(dst, value)
+	case slog.KindString:
+		return appendJSONString(dst, value.String())
+	}
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	text := logutils.AppendValue((*buf)[:0], value)
+	dst = appendJSONString(dst, text)
+	*buf = text
+	logutils.PutPool(logutils.SimplePool, buf)
+	return dst
+}
@@ -0,0 +1,274 @@
+package uslogs_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+// readGELFDatagrams reads UDP datagrams until it reassembled the given number of messages, and returns them
+// decompressed and decoded.
+func readGELFDatagrams(t *testing.T, conn net.PacketConn, messages int) []map[string]any {
+	t.Helper()
+	type pending struct {
+		chunks   [][]byte
+		received int
+	}
+	chunked := make(map[uint64]*pending)
+	var decoded []map[string]any
+	datagram := make([]byte, 65536)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(decoded) < messages {
+		size, _, err := conn.ReadFrom(datagram)
+		if err != nil {
+			t.Fatalf("got %d messages, expected %d: %v", len(decoded), messages, err)
+		}
+		payload := datagram[:size]
+		if len(payload) < 2 || payload[0] != 0x1e || payload[1] != 0x0f {
+			decoded = append(decoded, decodeGELF(t, bytes.Clone(payload)))
+			continue
+		}
+		id, seq, count := binary.BigEndian.Uint64(payload[2:10]), int(payload[10]), int(payload[11])
+		message, ok := chunked[id]
+		if !ok {
+			message = &pending{chunks: make([][]byte, count), received: 0}
+			chunked[id] = message
+		}
+		if message.chunks[seq] == nil {
+			message.chunks[seq] = bytes.Clone(payload[12:])
+			message.received++
+		}
+		if message.received == count {
+			decoded = append(decoded, decodeGELF(t, bytes.Join(message.chunks, nil)))
+			delete(chunked, id)
+		}
+	}
+	return decoded
+}
+
+func decodeGELF(t *testing.T, payload []byte) map[string]any {
+	t.Helper()
+	var reader io.Reader = bytes.NewReader(payload)
+	var err error
+	switch {
+	case bytes.HasPrefix(payload, []byte{0x1f, 0x8b}):
+		reader, err = gzip.NewReader(reader)
+	case len(payload) > 0 && payload[0] == 0x78:
+		reader, err = zlib.NewReader(reader)
+	}
+	if err != nil {
+		t.Fatalf("invalid compressed message: %v", err)
+	}
+	var message map[string]any
+	if err = json.NewDecoder(reader).Decode(&message); err != nil {
+		t.Fatalf("invalid message %q: %v", payload, err)
+	}
+	return message
+}
+
+func listenGELFUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+	//nolint:noctx
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestGELFWriter_WriteRecord(t *testing.T) {
+	conn := listenGELFUDP(t)
+	w, err := uslogs.NewGELFWriter("udp", conn.LocalAddr().String(), uslogs.WithGELFHost("web-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close() //nolint:errcheck
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(w),
+		uslogs.WithLevel(slog.LevelDebug),
+		uslogs.WithMaskedAttributes("password"),
+		uslogs.WithMaskedPatterns(
+			uslogs.MaskPattern{Start: "card=", Delimiters: []byte{' ', '\n'}},
+			uslogs.MaskPattern{Start: "token=", Delimiters: []byte{' ', '\n'}}),
+	)).WithGroup("http")
+
+	logger.Warn("login failed", "user", "alice", "password", "secret", "status", 401, "elapsed", 1.5,
+		"id", "r-1", "bad key", true, "token", "SECRET", "card", int64(4111111111111111))
+	logger.Debug("panic: boom\ngoroutine 1 [running]:\nmain.main() card=4111111111111111")
+
+	messages := readGELFDatagrams(t, conn, 2)
+	first := messages[0]
+	expected := map[string]any{
+		"version":        "1.1",
+		"host":           "web-1",
+		"short_message":  "login failed",
+		"level":          4.0,
+		"_http.user":     "alice",
+		"_http.password": "<MASKED>",
+		"_http.status":   401.0,
+		"_http.elapsed":  1.5,
+		"_http.id":       "r-1",
+		"_http.bad_key":  "true",
+		"_http.token":    "******",
+		"_http.card":     "****************",
+	}
+	for key, value := range expected {
+		if first[key] != value {
+			t.Errorf("got %s=%v, expected %v", key, first[key], value)
+		}
+	}
+	if _, ok := first["full_message"]; ok {
+		t.Errorf("unexpected full_message in %v", first)
+	}
+	if timestamp, _ := first["timestamp"].(float64); time.Since(time.Unix(int64(timestamp), 0)) > time.Minute {
+		t.Errorf("got timestamp %v, expected now", first["timestamp"])
+	}
+
+	second := messages[1]
+	if second["short_message"] != "panic: boom" || second["level"] != 7.0 {
+		t.Errorf("got short_message %v and level %v", second["short_message"], second["level"])
+	}
+	fullMessage, _ := second["full_message"].(string)
+	if !strings.HasPrefix(fullMessage, "panic: boom\ngoroutine 1") || !strings.HasSuffix(fullMessage, "card=****************") {
+		t.Errorf("got full_message %q", fullMessage)
+	}
+}
+
+func TestGELFWriter_ChunkedCompressedUDP(t *testing.T) {
+	for _, compression := range []uslogs.GELFCompression{
+		uslogs.GELFCompressNone, uslogs.GELFCompressGzip, uslogs.GELFCompressZlib,
+	} {
+		conn := listenGELFUDP(t)
+		w, err := uslogs.NewGELFWriter("udp", conn.LocalAddr().String(),
+			uslogs.WithGELFCompression(compression),
+			uslogs.WithGELFChunkSize(64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body strings.Builder
+		for idx := range 200 {
+			body.WriteString(time.Duration(idx).String())
+			body.WriteByte(' ')
+		}
+
+		if _, err = w.Write([]byte(body.String() + "\n")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err = w.Write([]byte("short\n")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		messages := readGELFDatagrams(t, conn, 2)
+		if messages[0]["short_message"] != body.String() {
+			t.Errorf("compression %d: got short_message %q", compression, messages[0]["short_message"])
+		}
+		if messages[1]["short_message"] != "short" || messages[1]["level"] != 6.0 {
+			t.Errorf("compression %d: got %v", compression, messages[1])
+		}
+		_ = w.Close()
+	}
+}
+
+func TestGELFWriter_TooManyChunks(t *testing.T) {
+	conn := listenGELFUDP(t)
+	w, err := uslogs.NewGELFWriter("udp", conn.LocalAddr().String(), uslogs.WithGELFChunkSize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	if _, err = w.Write(bytes.Repeat([]byte{'a'}, 1024)); !errors.Is(err, uslogs.ErrGELFTooLarge) {
+		t.Fatalf("got error %v, expected %v", err, uslogs.ErrGELFTooLarge)
+	}
+}
+
+func TestGELFWriter_NullFramedTCP(t *testing.T) {
+	//nolint:noctx
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		reader := bufio.NewReader(conn)
+		var frames []string
+		for range 2 {
+			frame, err := reader.ReadString(0)
+			if err != nil {
+				break
+			}
+			frames = append(frames, strings.TrimSuffix(frame, "\x00"))
+		}
+		received <- frames
+	}()
+
+	w, err := uslogs.NewGELFWriter("tcp", ln.Addr().String(),
+		uslogs.WithGELFHost("web-1"),
+		uslogs.WithGELFCompression(uslogs.GELFCompressGzip),
+		uslogs.WithGELFDefaultLevel(slog.LevelError))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close() //nolint:errcheck
+	logger := slog.New(uslogs.NewUnstructuredHandler(uslogs.WithWriter(w)))
+
+	logger.Info("first", "n", 1)
+	if _, err = w.Write([]byte("second\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	frames := <-received
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, expected 2", len(frames))
+	}
+	first := decodeGELF(t, []byte(frames[0]))
+	if first["short_message"] != "first" || first["_n"] != 1.0 || first["host"] != "web-1" {
+		t.Errorf("got %v", first)
+	}
+	second := decodeGELF(t, []byte(frames[1]))
+	if second["short_message"] != "second" || second["level"] != 3.0 {
+		t.Errorf("got %v", second)
+	}
+}
+
+func TestGELFWriter_WriteAfterClose(t *testing.T) {
+	//nolint:noctx
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	conn := listenGELFUDP(t)
+	for network, address := range map[string]string{"udp": conn.LocalAddr().String(), "tcp": ln.Addr().String()} {
+		w, err := uslogs.NewGELFWriter(network, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err = w.Write([]byte("late\n")); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("%s: got %v, expected %v", network, err, net.ErrClosed)
+		}
+	}
+}
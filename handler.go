@@ -1,12 +1,15 @@
 package uslogs
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"log/slog"
 	"os"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/Drathveloper/uslogs/internal/logutils"
 )
@@ -23,17 +26,157 @@ var levelNames = map[slog.Level][]byte{
 	slog.LevelError: []byte("ERROR"),
 }
 
+// LevelWriter is implemented by writers that need the level of the record behind every line, for example
+// to map it to a syslog severity. UnstructuredHandler calls WriteLevel instead of Write when available.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level slog.Level, input []byte) (int, error)
+}
+
+// KeyedWriter is implemented by writers that keep the lines sharing an ordering key in order, such as AsyncWriter
+// under OrderPerKey. UnstructuredHandler calls WriteKeyed instead of Write when the context of the record carries
+// a key set with ContextWithOrderingKey.
+type KeyedWriter interface {
+	io.Writer
+	WriteKeyed(key string, input []byte) (int, error)
+}
+
+// RecordWriter is implemented by writers that need the structured content of every record in addition to the
+// formatted line, for example to turn attributes into systemd journal fields. UnstructuredHandler calls
+// WriteRecord instead of Write when available.
+type RecordWriter interface {
+	io.Writer
+	WriteRecord(entry *Entry) error
+}
+
+// Entry is the structured content of a record handed to a RecordWriter. It is only valid during the
+// WriteRecord call and must not be retained.
+type Entry struct {
+	Time    time.Time
+	handler *UnstructuredHandler
+	Message string
+	// Line is the formatted line, after masking.
+	Line []byte
+	// Attrs holds the handler and record attributes, with keys qualified by their group path. Values of
+	// masked attributes are already replaced.
+	Attrs []slog.Attr
+	PC    uintptr
+	Level slog.Level
+}
+
+// Mask applies the handler's masked patterns in place to the given bytes and returns them.
+func (e *Entry) Mask(input []byte) []byte {
+	if e.handler.partialMasker != nil && len(e.handler.partialMaskPatterns) > 0 {
+		return e.handler.partialMasker.Mask(input, e.handler.partialMaskPatterns)
+	}
+	return input
+}
+
+// appendMaskedValue appends the formatted value of the given attribute, masked by the handler's patterns as it is
+// on a text line: preceded by its key and followed by a separator, so patterns that include the key match.
+func (e *Entry) appendMaskedValue(dst []byte, attr slog.Attr) []byte {
+	if e.handler.partialMasker == nil || len(e.handler.partialMaskPatterns) == 0 {
+		return logutils.AppendValue(dst, attr.Value)
+	}
+	start := len(dst)
+	dst = append(append(dst, attr.Key...), '=')
+	valueStart := len(dst)
+	dst = logutils.AppendValue(dst, attr.Value)
+	valueEnd := len(dst)
+	dst = append(dst, ' ', '\n')
+	e.handler.partialMasker.Mask(dst[start:], e.handler.partialMaskPatterns)
+	return dst[:start+copy(dst[start:], dst[valueStart:valueEnd])]
+}
+
+// appendMaskedAttrValue appends the value with appendValue, unless the handler's patterns mask part of its text
+// as they would on a text line, in which case the masked text is appended with appendText.
+func appendMaskedAttrValue(
+	l *UnstructuredHandler, dst []byte, key string, value slog.Value,
+	appendValue func([]byte, slog.Value) []byte, appendText func([]byte, []byte) []byte,
+) []byte {
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	text := logutils.AppendValue((*buf)[:0], value)
+	textEnd := len(text)
+	text = appendMaskedField(l, text, key, text[:textEnd], appendRawField)
+	if bytes.Equal(text[:textEnd], text[textEnd:]) {
+		dst = appendValue(dst, value)
+	} else {
+		dst = appendText(dst, text[textEnd:])
+	}
+	*buf = text
+	logutils.PutPool(logutils.SimplePool, buf)
+	return dst
+}
+
+func appendRawField(dst []byte, field []byte) []byte {
+	return append(dst, field...)
+}
+
+//nolint:gochecknoglobals
+var entryPool = sync.Pool{
+	New: func() any {
+		//nolint:exhaustruct
+		return &Entry{Attrs: make([]slog.Attr, 0, 16)} //nolint:mnd
+	},
+}
+
 // UnstructuredHandler writes log lines in plain text format.
 type UnstructuredHandler struct {
 	writer              io.Writer
+	levelWriter         LevelWriter
+	keyedWriter         KeyedWriter
+	recordWriter        RecordWriter
 	partialMasker       *logutils.Masker
 	group               []byte
 	attrs               []byte
+	entryAttrs          []slog.Attr
+	layout              []layoutOp
+	layoutFields        []string
+	jsonGroups          []string
+	groups              []string
+	pinnedKeys          []string
+	pinnedAttrs         [][]byte
+	droppedKeys         []string
+	renamedKeys         map[string]string
+	attrRules           map[string]attrRule
+	replaceAttr         ReplaceAttrFunc
+	contextExtractors   []ContextExtractor
+	traceSource         TraceSource
+	rateLimiter         *RateLimiter
+	deduplicator        *Deduplicator
 	maskedAttrs         []string
 	partialMaskPatterns []logutils.MaskPattern
+	timeKey             string
+	levelKey            string
+	messageKey          string
+	sourceKey           string
+	continuationMarker  string
+	deviceVendor        string
+	deviceProduct       string
+	deviceVersion       string
+	eventIDKey          string
+	keyMapping          map[string]string
+	stackFilter         StackFilter
+	levelWidth          int
+	jsonDepth           int
+	stackFrames         int
+	format              Format
+	colorMode           ColorMode
+	messageWidth        int
+	overflow            Overflow
+	multiline           Multiline
 	level               slog.Level
+	bufferLevel         slog.Level
+	traceSampleLevel    slog.Level
+	stackLevel          slog.Level
 	withTime            bool
 	isResponsivePool    bool
+	bufferRequests      bool
+	traceSampling       bool
+	stackTraces         bool
+	rewriting           bool
+	errorStackTraces    bool
+	colored             bool
 	separator           byte
 	groupSeparator      byte
 }
@@ -42,31 +185,103 @@ type UnstructuredHandler struct {
 func NewUnstructuredHandler(opts ...LogWriterOption) *UnstructuredHandler {
 	//nolint:exhaustruct
 	logWriter := &UnstructuredHandler{
-		separator:      ' ',
-		groupSeparator: '.',
-		maskedAttrs:    make([]string, 0),
-		writer:         os.Stdout,
-		level:          slog.LevelInfo,
+		separator:          ' ',
+		groupSeparator:     '.',
+		maskedAttrs:        make([]string, 0),
+		writer:             os.Stdout,
+		level:              slog.LevelInfo,
+		timeKey:            defaultTimeKey,
+		levelKey:           defaultLevelKey,
+		messageKey:         defaultMessageKey,
+		deviceProduct:      programName(),
+		eventIDKey:         defaultEventIDKey,
+		continuationMarker: defaultContinuationMarker,
+		stackFilter:        DefaultStackFilter,
+		stackFrames:        defaultStackFrames,
 	}
 	for _, opt := range opts {
 		opt(logWriter)
 	}
+	if logWriter.format != FormatText {
+		logWriter.separator = ' '
+	}
+	logWriter.compileAttrRules()
+	logWriter.colored = logWriter.useColor()
 	return logWriter
 }
 
-// Enabled returns true if the log level is greater than or equal to the configured level.
-func (l *UnstructuredHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return l.level <= level
+// Enabled returns true if the log level is greater than or equal to the configured level, or to the request
+// buffering level when the context carries a RequestBuffer.
+func (l *UnstructuredHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if l.level <= level {
+		return true
+	}
+	return l.bufferRequests && l.bufferLevel <= level && RequestBufferFromContext(ctx) != nil
 }
 
-// Handle writes the log line to the writer.
-func (l *UnstructuredHandler) Handle(_ context.Context, record slog.Record) error {
+// Handle writes the log line to the writer, or holds it in the RequestBuffer carried by the context.
+func (l *UnstructuredHandler) Handle(ctx context.Context, record slog.Record) error {
+	var traceParent TraceParent
+	var traced bool
+	if l.traceSource != nil {
+		traceParent, traced = l.traceSource.TraceParent(ctx)
+		if traced && l.traceSampling && !traceParent.Sampled() && record.Level < l.traceSampleLevel {
+			return nil
+		}
+	}
 	attrBuf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
 	attrBytes := (*attrBuf)[:0]
+	var pins *pinnedAttrs
+	if len(l.pinnedKeys) > 0 {
+		pins = newPinnedAttrs()
+	}
+	if traced {
+		attrBytes = l.appendTraceAttrs(attrBytes, pins, &traceParent)
+	}
+	var contextBuf *[]slog.Attr
+	var contextAttrs []slog.Attr
+	if len(l.contextExtractors) > 0 || (traced && l.recordWriter != nil) {
+		contextBuf = contextAttrsPool.Get().(*[]slog.Attr) //nolint:forcetypeassert
+		contextAttrs = (*contextBuf)[:0]
+		if traced && l.recordWriter != nil {
+			contextAttrs = traceEntryAttrs(contextAttrs, &traceParent)
+		}
+		extracted := len(contextAttrs)
+		for _, extract := range l.contextExtractors {
+			contextAttrs = extract(ctx, contextAttrs)
+		}
+		for _, attr := range contextAttrs[extracted:] {
+			if !slices.Contains(l.layoutFields, attr.Key) {
+				attrBytes = l.appendRewrittenAttr(attrBytes, pins, nil, nil, attr)
+			}
+		}
+	}
+	var stack string
+	if l.capturesStack(record) {
+		stack = l.stackTrace(record)
+		if !l.stackInBlock() {
+			attrBytes = l.appendRewrittenAttr(attrBytes, pins, nil, nil, slog.String(stackKey, stack))
+		}
+	}
+	recordStart := len(attrBytes)
 	record.Attrs(func(attr slog.Attr) bool {
-		attrBytes = l.appendAttr(attrBytes, attr)
+		attrBytes = l.appendRewrittenAttr(attrBytes, pins, l.group, l.groups, attr)
 		return true
 	})
+	handlerAttrs := l.attrs
+	if pins != nil {
+		// Pinned attributes go first: before the context attributes in FormatJSON lines, where handler attributes
+		// follow them, and before the handler attributes otherwise.
+		held := len(pins.bytes)
+		pins.bytes = l.appendPinned(pins.bytes, pins)
+		lead := pins.bytes[held:]
+		if l.format == FormatJSON {
+			recordStart += len(lead)
+			attrBytes = append(lead, attrBytes...)
+		} else {
+			handlerAttrs = append(lead, l.attrs...)
+		}
+	}
 	var pool *sync.Pool
 	if l.isResponsivePool {
 		pool = logutils.BytesPools.GetPool(len(record.Message) + len(l.attrs) + len(attrBytes))
@@ -74,31 +289,28 @@ func (l *UnstructuredHandler) Handle(_ context.Context, record slog.Record) erro
 		pool = logutils.SimplePool
 	}
 	buf := pool.Get().(*[]byte) //nolint:forcetypeassert
-	bytes := (*buf)[:0]
-
-	if l.withTime {
-		bytes = logutils.AppendTimeRFC3339(bytes, record.Time.UTC())
-		bytes = logutils.AppendSeparator(bytes, l.separator)
+	bytes, timeStart, timeEnd := l.appendLine((*buf)[:0], record, handlerAttrs, attrBytes, recordStart, contextAttrs)
+	if stack != "" && l.stackInBlock() {
+		bytes = l.appendBlockLines(bytes, stack)
 	}
-	bytes = append(bytes, levelNames[record.Level]...)
-	bytes = logutils.AppendSeparator(bytes, l.separator)
-	bytes = append(bytes, record.Message...)
-	bytes = append(bytes, l.attrs...)
-	bytes = append(bytes, attrBytes...)
-	bytes = append(bytes, '\n')
 
-	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 {
+	// Structured formats mask their fields before they are encoded.
+	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 && l.format == FormatText {
 		bytes = l.partialMasker.Mask(bytes, l.partialMaskPatterns)
 	}
 
-	if _, err := l.writer.Write(bytes); err != nil {
-		logutils.PutPool(logutils.SimplePool, attrBuf)
-		logutils.PutPool(pool, buf)
-		return err //nolint:wrapcheck
+	err := l.handleLine(ctx, record, contextAttrs, bytes, timeStart, timeEnd)
+	if contextBuf != nil {
+		clear(contextAttrs)
+		*contextBuf = contextAttrs[:0]
+		contextAttrsPool.Put(contextBuf)
+	}
+	if pins != nil {
+		pins.release()
 	}
 	logutils.PutPool(logutils.SimplePool, attrBuf)
 	logutils.PutPool(pool, buf)
-	return nil
+	return err
 }
 
 // WithAttrs adds attributes to the log line.
@@ -106,13 +318,47 @@ func (l *UnstructuredHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	if len(attrs) == 0 {
 		return l
 	}
+	if l.rewriting {
+		rewritten := make([]slog.Attr, 0, len(attrs))
+		for _, attr := range attrs {
+			if attr, ok := l.rewriteAttr(l.groups, attr); ok {
+				rewritten = append(rewritten, attr)
+			}
+		}
+		if len(rewritten) == 0 {
+			return l
+		}
+		attrs = rewritten
+	}
 	clonedLogWriter := l.clone()
 	b := make([]byte, 0, len(clonedLogWriter.attrs)+1024) //nolint:mnd
 	b = append(b, clonedLogWriter.attrs...)
+	if l.format == FormatJSON {
+		b = clonedLogWriter.openJSONGroups(b)
+	}
+	var pins *pinnedAttrs
+	if len(l.pinnedKeys) > 0 {
+		pins = newPinnedAttrs()
+	}
 	for _, attr := range attrs {
-		b = l.appendAttr(b, attr)
+		b = l.appendPinnableAttr(b, pins, l.group, attr)
 	}
 	clonedLogWriter.attrs = b
+	if pins != nil {
+		clonedLogWriter.pinnedAttrs = slices.Clone(l.pinnedAttrs)
+		for _, span := range pins.spans {
+			pinned := slices.Clip(clonedLogWriter.pinnedAttrs[span.pin])
+			clonedLogWriter.pinnedAttrs[span.pin] = append(pinned, pins.bytes[span.start:span.end]...)
+		}
+		pins.release()
+	}
+	if l.recordWriter != nil {
+		entryAttrs := slices.Clone(l.entryAttrs)
+		for _, attr := range attrs {
+			entryAttrs = l.appendEntryAttr(entryAttrs, l.group, attr)
+		}
+		clonedLogWriter.entryAttrs = entryAttrs
+	}
 	return clonedLogWriter
 }
 
@@ -122,6 +368,12 @@ func (l *UnstructuredHandler) WithGroup(name string) slog.Handler {
 		return l
 	}
 	clonedLogWriter := l.clone()
+	if l.format == FormatJSON {
+		clonedLogWriter.jsonGroups = append(slices.Clip(l.jsonGroups), name)
+	}
+	if l.rewriting {
+		clonedLogWriter.groups = append(slices.Clip(l.groups), name)
+	}
 	if len(clonedLogWriter.group) == 0 {
 		clonedLogWriter.group = []byte(name)
 		return clonedLogWriter
@@ -131,6 +383,148 @@ func (l *UnstructuredHandler) WithGroup(name string) slog.Handler {
 	return clonedLogWriter
 }
 
+// appendLine appends the formatted line of the given record, followed by the given formatted handler and record
+// attributes, and returns the position of the timestamp in it. attrBytes holds the trace and context attributes
+// followed, from recordStart, by the attributes of the record.
+func (l *UnstructuredHandler) appendLine(
+	dst []byte, record slog.Record, handlerAttrs, attrBytes []byte, recordStart int, contextAttrs []slog.Attr,
+) ([]byte, int, int) {
+	switch {
+	case l.format == FormatJSON:
+		return l.appendJSONLine(dst, record, handlerAttrs, attrBytes[:recordStart], attrBytes[recordStart:])
+	case l.format == FormatCEF || l.format == FormatLEEF:
+		return l.appendCEFLine(dst, record, handlerAttrs, attrBytes)
+	case l.format == FormatLogfmt:
+		return l.appendLogfmtLine(dst, record, handlerAttrs, attrBytes)
+	case l.layout != nil:
+		return l.appendLayout(dst, record, handlerAttrs, attrBytes, contextAttrs)
+	}
+	lineStart := len(dst)
+	timeStart, timeEnd := len(dst), len(dst)
+	if l.withTime {
+		dst = l.appendTime(dst, record.Time, "")
+		dst = logutils.AppendSeparator(dst, l.separator)
+		timeEnd = len(dst)
+	}
+	dst = l.appendLevel(dst, record.Level)
+	dst = logutils.AppendSeparator(dst, l.separator)
+	dst = l.appendMessage(dst, record.Message, lineStart, len(handlerAttrs) > 0 || len(attrBytes) > 0)
+	dst = append(dst, handlerAttrs...)
+	dst = append(dst, attrBytes...)
+	return l.appendBlock(append(dst, '\n'), record), timeStart, timeEnd
+}
+
+// handleLine writes the formatted line unless it duplicates the previous one, the rate limiter drops it or the
+// RequestBuffer carried by the context holds it.
+func (l *UnstructuredHandler) handleLine(
+	ctx context.Context, record slog.Record, contextAttrs []slog.Attr, line []byte, timeStart, timeEnd int,
+) error {
+	if l.deduplicator != nil {
+		if write, err := l.deduplicator.observe(l, record, line, timeStart, timeEnd); !write || err != nil {
+			return err
+		}
+	}
+	if l.rateLimiter != nil {
+		allowed, suppressed := l.rateLimiter.allow(l, record.Level, len(line))
+		if !allowed {
+			return nil
+		}
+		if err := writeSuppressed(l, record.Time, suppressed); err != nil {
+			return err
+		}
+	}
+	var key string
+	if l.keyedWriter != nil {
+		key = OrderingKeyFromContext(ctx)
+	}
+	if !l.bufferRequests {
+		return l.write(key, record, contextAttrs, line)
+	}
+	buffer := RequestBufferFromContext(ctx)
+	if buffer == nil {
+		return l.write(key, record, contextAttrs, line)
+	}
+	held, err := buffer.hold(l, key, record, contextAttrs, line)
+	if held || !buffer.admits(l, record.Level) {
+		return err
+	}
+	return errors.Join(err, l.write(key, record, contextAttrs, line))
+}
+
+// writeSummary writes a line with the given level and message, and no attributes, in the handler format.
+func (l *UnstructuredHandler) writeSummary(now time.Time, level slog.Level, message string) error {
+	record := slog.NewRecord(now, level, message, 0)
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	line, _, _ := l.appendLine((*buf)[:0], record, nil, nil, 0, nil)
+	err := l.write("", record, nil, line)
+	*buf = line
+	logutils.PutPool(logutils.SimplePool, buf)
+	return err
+}
+
+// write hands the line to the writer, along with the content of the record or the ordering key if the writer
+// takes them.
+func (l *UnstructuredHandler) write(key string, record slog.Record, contextAttrs []slog.Attr, line []byte) error {
+	var err error
+	switch {
+	case l.recordWriter != nil:
+		err = l.writeEntry(record, contextAttrs, line)
+	case l.levelWriter != nil:
+		_, err = l.levelWriter.WriteLevel(record.Level, line)
+	case l.keyedWriter != nil && key != "":
+		_, err = l.keyedWriter.WriteKeyed(key, line)
+	default:
+		_, err = l.writer.Write(line)
+	}
+	return err //nolint:wrapcheck
+}
+
+func (l *UnstructuredHandler) writeEntry(record slog.Record, contextAttrs []slog.Attr, line []byte) error {
+	entry := entryPool.Get().(*Entry) //nolint:forcetypeassert
+	entry.handler = l
+	entry.Time = record.Time
+	entry.Message = record.Message
+	entry.Line = line
+	entry.PC = record.PC
+	entry.Level = record.Level
+	entry.Attrs = append(entry.Attrs[:0], l.entryAttrs...)
+	for _, attr := range contextAttrs {
+		if attr, ok := l.rewrite(nil, attr); ok {
+			entry.Attrs = l.appendEntryAttr(entry.Attrs, nil, attr)
+		}
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr, ok := l.rewrite(l.groups, attr); ok {
+			entry.Attrs = l.appendEntryAttr(entry.Attrs, l.group, attr)
+		}
+		return true
+	})
+	err := l.recordWriter.WriteRecord(entry)
+	*entry = Entry{Attrs: entry.Attrs[:0]} //nolint:exhaustruct
+	entryPool.Put(entry)
+	return err //nolint:wrapcheck
+}
+
+// appendEntryAttr flattens the given attribute into entry attributes whose keys are qualified by the group path.
+func (l *UnstructuredHandler) appendEntryAttr(attrs []slog.Attr, group []byte, attr slog.Attr) []slog.Attr {
+	key := attr.Key
+	if len(group) != 0 {
+		key = string(group) + string(l.groupSeparator) + key
+	}
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		nested := []byte(key)
+		for _, groupAttr := range value.Group() {
+			attrs = l.appendEntryAttr(attrs, nested, groupAttr)
+		}
+		return attrs
+	}
+	if slices.Contains(l.maskedAttrs, attr.Key) {
+		value = slog.StringValue(maskedFieldValue)
+	}
+	return append(attrs, slog.Attr{Key: key, Value: value})
+}
+
 func (l *UnstructuredHandler) clone() *UnstructuredHandler {
 	if l == nil {
 		return nil
@@ -139,18 +533,58 @@ func (l *UnstructuredHandler) clone() *UnstructuredHandler {
 	return &clone
 }
 
-func (l *UnstructuredHandler) appendAttr(input []byte, attr slog.Attr) []byte {
+func (l *UnstructuredHandler) appendGroupedAttr(input []byte, group []byte, attr slog.Attr) []byte {
+	switch l.format {
+	case FormatLogfmt:
+		return l.appendLogfmtAttr(input, group, attr)
+	case FormatJSON:
+		return l.appendJSONAttr(input, attr)
+	case FormatCEF, FormatLEEF:
+		return l.appendCEFAttr(input, group, attr)
+	case FormatText:
+	}
 	input = logutils.AppendSeparator(input, l.separator)
-	if len(l.group) != 0 {
-		input = append(input, l.group...)
+	if l.colored {
+		input = append(input, ansiCyan...)
+	}
+	if len(group) != 0 {
+		input = append(input, group...)
 		input = append(input, l.groupSeparator)
 	}
 	input = append(input, attr.Key...)
+	if l.colored {
+		input = append(input, ansiReset...)
+	}
 	input = append(input, '=')
-	if slices.Contains(l.maskedAttrs, attr.Key) {
+	valueStart := len(input)
+	switch {
+	case slices.Contains(l.maskedAttrs, attr.Key):
 		input = append(input, maskedFieldValue...)
-	} else {
+	case l.colored && isErrorValue(attr.Value):
+		input = append(input, ansiRed...)
+		input = logutils.AppendValue(input, attr.Value)
+		input = append(input, ansiReset...)
+	default:
 		input = logutils.AppendValue(input, attr.Value)
 	}
+	if l.multiline != MultilineKeep {
+		input = l.foldLines(input, valueStart)
+	}
 	return input
 }
+
+// appendTime appends the time in UTC, using the given layout or RFC 3339 when it is empty.
+func (l *UnstructuredHandler) appendTime(dst []byte, timestamp time.Time, layout string) []byte {
+	if l.colored {
+		dst = append(dst, ansiDim...)
+	}
+	if layout == "" {
+		dst = logutils.AppendTimeRFC3339(dst, timestamp.UTC())
+	} else {
+		dst = timestamp.UTC().AppendFormat(dst, layout)
+	}
+	if l.colored {
+		dst = append(dst, ansiReset...)
+	}
+	return dst
+}
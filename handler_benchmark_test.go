@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -240,3 +241,141 @@ func BenchmarkSlogWriter_HandleWithAll_65Responsive(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkLogWriter_HandleFlightRecorder(b *testing.B) {
+	recorder := uslogs.NewFlightRecorder(output)
+	writer := uslogs.NewUnstructuredHandler(uslogs.WithFlightRecorder(recorder))
+	record := slog.NewRecord(time.Now(), slog.LevelDebug, "It was a simple tip of the hat. Grace didn't think that anyone else besides her had even noticed it", 0)
+	record.Add(slog.String("foo", "bar"), slog.Int("baz", 25))
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_ = writer.Handle(context.Background(), record)
+	}
+}
+
+func BenchmarkLogWriter_HandleContextAttrs(b *testing.B) {
+	writer := uslogs.NewUnstructuredHandler(
+		uslogs.WithLevel(slog.LevelInfo),
+		uslogs.WithWriter(output),
+		uslogs.WithContextExtractors(uslogs.AppendContextAttrs))
+	ctx := uslogs.ContextWithAttrs(context.Background(), slog.String("request_id", "r-1"), slog.String("tenant", "acme"))
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "request served", 0)
+	record.AddAttrs(slog.Int("status", 200))
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = writer.Handle(ctx, record)
+		}
+	})
+}
+
+func BenchmarkLogWriter_HandleTraceContext(b *testing.B) {
+	writer := uslogs.NewUnstructuredHandler(
+		uslogs.WithLevel(slog.LevelInfo),
+		uslogs.WithWriter(output),
+		uslogs.WithTraceContext(uslogs.ContextTraceSource{}))
+	traceParent, _ := uslogs.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := uslogs.ContextWithTraceParent(context.Background(), traceParent)
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "request served", 0)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = writer.Handle(ctx, record)
+		}
+	})
+}
+
+func BenchmarkSamplingHandler_Handle(b *testing.B) {
+	handler := uslogs.NewSamplingHandler(uslogs.NewUnstructuredHandler(
+		uslogs.WithLevel(slog.LevelInfo),
+		uslogs.WithWriter(output)), time.Second, 100, 100)
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "request served", 0)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = handler.Handle(context.Background(), record)
+		}
+	})
+}
+
+func BenchmarkLogWriter_HandleDeduplicator(b *testing.B) {
+	writer := uslogs.NewUnstructuredHandler(
+		uslogs.WithLevel(slog.LevelInfo),
+		uslogs.WithWriter(output),
+		uslogs.WithTimestamp(),
+		uslogs.WithDeduplicator(uslogs.NewDeduplicator(time.Second)))
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "It was a simple tip of the hat. Grace didn't think that anyone else besides her had even noticed it", 0)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = writer.Handle(context.Background(), record)
+		}
+	})
+}
+
+func BenchmarkLogWriter_HandleLayout(b *testing.B) {
+	writer := uslogs.NewUnstructuredHandler(
+		uslogs.WithLevel(slog.LevelInfo),
+		uslogs.WithWriter(output),
+		uslogs.WithSeparator('|'),
+		uslogs.WithLayout("%time{2006-01-02 15:04:05.000} [%level] (%source) %msg%attrs"))
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "It was a simple tip of the hat. Grace didn't think that anyone else besides her had even noticed it", pcs[0])
+	record.AddAttrs(slog.Int("status", 200))
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = writer.Handle(context.Background(), record)
+		}
+	})
+}
+
+func BenchmarkLogWriter_HandleLogfmt(b *testing.B) {
+	writer := uslogs.NewUnstructuredHandler(
+		uslogs.WithLevel(slog.LevelInfo),
+		uslogs.WithWriter(output),
+		uslogs.WithTimestamp(),
+		uslogs.WithFormat(uslogs.FormatLogfmt))
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "It was a simple tip of the hat. Grace didn't think that anyone else besides her had even noticed it", 0)
+	record.AddAttrs(slog.String("path", "/a b"), slog.Int("status", 200))
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = writer.Handle(context.Background(), record)
+		}
+	})
+}
+
+func BenchmarkLogWriter_HandleJSON(b *testing.B) {
+	writer := uslogs.NewUnstructuredHandler(
+		uslogs.WithLevel(slog.LevelInfo),
+		uslogs.WithWriter(output),
+		uslogs.WithTimestamp(),
+		uslogs.WithFormat(uslogs.FormatJSON))
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "It was a simple tip of the hat. Grace didn't think that anyone else besides her had even noticed it", 0)
+	record.AddAttrs(slog.String("path", "/a b"), slog.Int("status", 200))
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = writer.Handle(context.Background(), record)
+		}
+	})
+}
@@ -16,10 +16,15 @@ type MaskPattern struct {
 // LogWriterOption represents a function that configures a log writer.
 type LogWriterOption = func(w *UnstructuredHandler)
 
-// WithWriter sets the writer to be used by the handler.
+// WithWriter sets the writer to be used by the handler. If the writer implements RecordWriter or LevelWriter,
+// it receives the content of every record along with the line; if it implements KeyedWriter, it receives the
+// ordering key of the records logged with one.
 func WithWriter(writer io.Writer) LogWriterOption {
 	return func(logWriter *UnstructuredHandler) {
 		logWriter.writer = writer
+		logWriter.levelWriter, _ = writer.(LevelWriter)
+		logWriter.keyedWriter, _ = writer.(KeyedWriter)
+		logWriter.recordWriter, _ = writer.(RecordWriter)
 	}
 }
 
@@ -51,7 +56,8 @@ func WithMaskedAttributes(attrs ...string) LogWriterOption {
 	}
 }
 
-// WithMaskedPatterns masks all attributes that start with the given pattern.
+// WithMaskedPatterns masks all attributes that start with the given pattern. Structured formats apply the
+// patterns to the message and to each attribute as key=value before encoding them.
 func WithMaskedPatterns(patterns ...MaskPattern) LogWriterOption {
 	return func(logWriter *UnstructuredHandler) {
 		dict := make([]string, 0, len(patterns))
@@ -71,3 +77,252 @@ func WithResponsivePool() LogWriterOption {
 		logWriter.isResponsivePool = true
 	}
 }
+
+// WithSinks formats every record once and dispatches it to the given sinks through a FanoutWriter. It replaces
+// the writer and sets the handler level to the lowest sink level.
+func WithSinks(sinks ...Sink) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		fanoutWriter := NewFanoutWriter(sinks...)
+		WithWriter(fanoutWriter)(logWriter)
+		logWriter.level = fanoutWriter.MinLevel()
+	}
+}
+
+// WithFlightRecorder writes every record to the given FlightRecorder and sets the handler level to the lowest
+// level it keeps in memory.
+func WithFlightRecorder(recorder *FlightRecorder) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		WithWriter(recorder)(logWriter)
+		logWriter.level = recorder.MinLevel()
+	}
+}
+
+// WithRequestBuffering holds the records logged with a context carrying a RequestBuffer until the request
+// finishes. Records from the given level up are enabled for such contexts even below the handler level, and
+// are only written if the request fails.
+func WithRequestBuffering(level slog.Level) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.bufferRequests = true
+		logWriter.bufferLevel = level
+	}
+}
+
+// WithContextExtractors appends the attributes returned by the given extractors to every line, right after the
+// handler attributes and outside of any group. Extractors run in order on every record.
+func WithContextExtractors(extractors ...ContextExtractor) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.contextExtractors = append(logWriter.contextExtractors, extractors...)
+	}
+}
+
+// WithTraceContext appends the trace_id and span_id of the span returned by the given TraceSource to every line
+// logged with a traced context, right after the handler attributes. Like other attributes, they can be renamed,
+// dropped, pinned or replaced.
+func WithTraceContext(source TraceSource) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.traceSource = source
+	}
+}
+
+// WithTraceSampling drops the lines below the given level that are logged with the context of a trace whose
+// sampled flag is not set, so logs follow the sampling decision of traces. It requires WithTraceContext.
+func WithTraceSampling(level slog.Level) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.traceSampling = true
+		logWriter.traceSampleLevel = level
+	}
+}
+
+// WithRateLimiter drops the lines rejected by the given RateLimiter and writes a summary of the suppressed lines
+// before the next line it accepts, or once its summary interval elapses. Handlers derived with WithAttrs and
+// WithGroup share the limiter.
+func WithRateLimiter(limiter *RateLimiter) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.rateLimiter = limiter
+	}
+}
+
+// WithDeduplicator collapses identical lines with the given Deduplicator. Handlers derived with WithAttrs
+// and WithGroup share it.
+func WithDeduplicator(deduplicator *Deduplicator) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.deduplicator = deduplicator
+	}
+}
+
+// WithLayout sets the template of every line, compiled once into the steps appended for each record. It
+// supports the tokens %time, %time{layout} with a time.Format layout, %level, %msg, %attrs, %source for the
+// "file:line" of the caller, %ctx{key} for the value of a context attribute, which is then left out of %attrs,
+// and %% for a percent sign. Unknown tokens are written as they are. Times are always in UTC.
+//
+// For example, "%time{2006-01-02 15:04:05.000} [%level] (%source) %msg%attrs". WithTimestamp has no effect
+// with a layout.
+func WithLayout(template string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.layout = compileLayout(template)
+		logWriter.layoutFields = layoutFields(logWriter.layout)
+	}
+}
+
+// WithLevelWidth pads the level name with spaces to the given width, so messages start on the same column.
+func WithLevelWidth(width int) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.levelWidth = width
+	}
+}
+
+// WithMessageWidth pads messages with spaces to the given width when attributes follow, so attributes start on
+// the same column. Longer messages are handled according to the given Overflow.
+func WithMessageWidth(width int, overflow Overflow) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.messageWidth = width
+		logWriter.overflow = overflow
+	}
+}
+
+// WithColor colors levels, timestamps, attribute keys and error values with ANSI escape sequences according to
+// the given ColorMode. Colors are never used along with WithMaskedPatterns.
+func WithColor(mode ColorMode) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.colorMode = mode
+	}
+}
+
+// WithFormat sets the encoding of the lines. Formats other than FormatText always separate attributes with a
+// single space and ignore WithLayout, the column options and WithColor.
+func WithFormat(format Format) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.format = format
+	}
+}
+
+// WithTimeKey sets the key of the timestamp in FormatJSON and FormatLogfmt lines. Defaults to "time".
+func WithTimeKey(key string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.timeKey = key
+	}
+}
+
+// WithLevelKey sets the key of the level in FormatJSON and FormatLogfmt lines. Defaults to "level".
+func WithLevelKey(key string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.levelKey = key
+	}
+}
+
+// WithMessageKey sets the key of the message in FormatJSON and FormatLogfmt lines. Defaults to "msg".
+func WithMessageKey(key string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.messageKey = key
+	}
+}
+
+// WithSourceKey adds the "file:line" of the caller under the given key to FormatJSON and FormatLogfmt lines,
+// right after the message.
+func WithSourceKey(key string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.sourceKey = key
+	}
+}
+
+// WithDeviceInfo sets the vendor, product and version of the FormatCEF and FormatLEEF headers. The product
+// defaults to the program name, the vendor and version to empty fields.
+func WithDeviceInfo(vendor, product, version string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.deviceVendor = vendor
+		logWriter.deviceProduct = product
+		logWriter.deviceVersion = version
+	}
+}
+
+// WithEventIDKey sets the key of the record attribute used as the signature id of FormatCEF lines and the event
+// id of FormatLEEF lines, instead of being written as an attribute. Defaults to "event_id".
+func WithEventIDKey(key string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.eventIDKey = key
+	}
+}
+
+// WithKeyMapping replaces the keys of attributes in FormatCEF and FormatLEEF lines, for example "user" by
+// "suser". Keys of grouped attributes are qualified by their group path.
+func WithKeyMapping(mapping map[string]string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.keyMapping = mapping
+	}
+}
+
+// WithMultiline sets how line breaks inside messages and attribute values are written in FormatText lines.
+// The marker starts continuation lines with MultilineIndent and MultilineBlock; an empty marker keeps the
+// default tab. Other formats always escape line breaks.
+func WithMultiline(policy Multiline, marker string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.multiline = policy
+		if marker != "" {
+			logWriter.continuationMarker = marker
+		}
+	}
+}
+
+// WithStackTrace captures the stack of the goroutine for records at or above the given level, keeping at most
+// maxFrames frames; a non-positive maxFrames keeps the default of 32. The stack is written as the "stack"
+// attribute with its frames joined by " <- ", or as a block after the line with one frame per line with
+// MultilineIndent and MultilineBlock.
+func WithStackTrace(level slog.Level, maxFrames int) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.stackTraces = true
+		logWriter.stackLevel = level
+		if maxFrames > 0 {
+			logWriter.stackFrames = maxFrames
+		}
+	}
+}
+
+// WithErrorStackTrace captures the stack of the goroutine for records with an error attribute, whatever their
+// level.
+func WithErrorStackTrace() LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.errorStackTraces = true
+	}
+}
+
+// WithStackFilter sets which frames of captured stacks are kept. Defaults to DefaultStackFilter; a nil filter
+// keeps every frame.
+func WithStackFilter(filter StackFilter) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.stackFilter = filter
+	}
+}
+
+// WithReplaceAttr rewrites every attribute before it is written, as slog.HandlerOptions.ReplaceAttr does, after
+// the renamed and dropped keys are applied. The time, level and message of the line are not attributes.
+func WithReplaceAttr(replace ReplaceAttrFunc) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.replaceAttr = replace
+	}
+}
+
+// WithRenamedKeys renames attribute keys, for example "msg" to "message". Keys of grouped attributes are
+// qualified by their group path, and renamed within their group. The keys of the time, level, message and source
+// of FormatJSON and FormatLogfmt lines are renamed too. Masked attributes are matched by their original key.
+func WithRenamedKeys(mapping map[string]string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.renamedKeys = mapping
+	}
+}
+
+// WithDroppedKeys drops the attributes with the given keys. Keys of grouped attributes are qualified by their
+// group path.
+func WithDroppedKeys(keys ...string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.droppedKeys = keys
+	}
+}
+
+// WithPinnedKeys writes the attributes with the given keys first, in the given order, whether they were added
+// to the handler, extracted from the context or passed with the record. Only attributes outside groups are
+// pinned.
+func WithPinnedKeys(keys ...string) LogWriterOption {
+	return func(logWriter *UnstructuredHandler) {
+		logWriter.pinnedKeys = keys
+	}
+}
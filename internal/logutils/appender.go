@@ -38,3 +38,14 @@ func AppendSeparator(bytes []byte, sep byte) []byte {
 	}
 	return append(bytes, ' ', sep, ' ')
 }
+
+// HexDigits are the lowercase hexadecimal digits, indexed by their value.
+const HexDigits = "0123456789abcdef"
+
+// AppendHex appends the lowercase hexadecimal encoding of the given bytes to a byte slice.
+func AppendHex(bytes []byte, src []byte) []byte {
+	for _, b := range src {
+		bytes = append(bytes, HexDigits[b>>4], HexDigits[b&0x0f]) //nolint:mnd
+	}
+	return bytes
+}
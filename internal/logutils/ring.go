@@ -0,0 +1,195 @@
+package logutils
+
+import (
+	"math"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ringAlign is the granularity used to reserve space in the arena. Every record starts
+	// at a multiple of ringAlign so its commit mark can be found with a single division.
+	ringAlign = 16
+
+	// markPadding flags the tail of the arena that was skipped by a record that wrapped.
+	markPadding uint32 = math.MaxUint32
+
+	// ringSpins is the number of times a producer yields while the arena is full before it
+	// starts sleeping, up to ringMaxBackoff between attempts.
+	ringSpins      = 16
+	ringMaxBackoff = time.Millisecond
+)
+
+// RingBuffer is a lock-free multi-producer single-consumer queue of byte records stored
+// in a shared arena.
+//
+// Producers reserve space by advancing the head with a CAS, copy their record into the
+// arena and publish it by storing its length in a commit mark. The single consumer walks
+// the arena from the tail, waiting for every record to be committed before handing it out.
+type RingBuffer struct {
+	arena   []byte
+	marks   []atomic.Uint32
+	notify  chan struct{}
+	mask    uint64
+	head    atomic.Uint64
+	tail    atomic.Uint64
+	waiting atomic.Bool
+}
+
+// NewRingBuffer creates a new RingBuffer with at least the given size in bytes. The size is
+// rounded up to the next power of two.
+func NewRingBuffer(size int) *RingBuffer {
+	capacity := uint64(ringAlign)
+	for capacity < uint64(size) { //nolint:gosec
+		capacity <<= 1
+	}
+	return &RingBuffer{
+		arena:   make([]byte, capacity),
+		marks:   make([]atomic.Uint32, capacity/ringAlign),
+		notify:  make(chan struct{}, 1),
+		mask:    capacity - 1,
+		head:    atomic.Uint64{},
+		tail:    atomic.Uint64{},
+		waiting: atomic.Bool{},
+	}
+}
+
+// Cap returns the size of the arena in bytes.
+func (r *RingBuffer) Cap() int {
+	return len(r.arena)
+}
+
+// Len returns the number of bytes reserved in the arena, including records not yet committed.
+func (r *RingBuffer) Len() int {
+	return int(r.head.Load() - r.tail.Load()) //nolint:gosec
+}
+
+// Fits reports whether a record of the given length can ever fit in the arena.
+func (r *RingBuffer) Fits(length int) bool {
+	return alignRecord(uint64(length)) <= uint64(len(r.arena)) && uint64(length) < uint64(markPadding) //nolint:gosec
+}
+
+// Push copies the header followed by the payload into the arena as a single record, waiting
+// with a growing backoff while there is not enough free space. The header may be nil. It
+// returns false if the record can never fit in the arena.
+func (r *RingBuffer) Push(header, payload []byte) bool {
+	return r.push(header, payload, true)
+}
+
+// TryPush copies the header followed by the payload into the arena as a single record. It
+// returns false without waiting if there is not enough free space, or if the record can
+// never fit in the arena.
+func (r *RingBuffer) TryPush(header, payload []byte) bool {
+	return r.push(header, payload, false)
+}
+
+func (r *RingBuffer) push(header, payload []byte, wait bool) bool {
+	length := len(header) + len(payload)
+	if !r.Fits(length) {
+		return false
+	}
+	size := uint64(len(r.arena))
+	need := alignRecord(uint64(length))
+	var head, offset, padding uint64
+	for attempt := 0; ; {
+		head = r.head.Load()
+		offset = head & r.mask
+		padding = 0
+		if offset+need > size {
+			padding = size - offset
+		}
+		if head+padding+need-r.tail.Load() > size {
+			if !wait {
+				return false
+			}
+			backoff(attempt)
+			attempt++
+			continue
+		}
+		if r.head.CompareAndSwap(head, head+padding+need) {
+			break
+		}
+	}
+	if padding > 0 {
+		r.marks[offset/ringAlign].Store(markPadding)
+		offset = 0
+	}
+	copy(r.arena[offset+uint64(copy(r.arena[offset:], header)):], payload)
+	r.marks[offset/ringAlign].Store(uint32(length) + 1) //nolint:gosec
+	// The consumer publishes that it is about to sleep before checking for committed records,
+	// so either it sees this record or we see it waiting.
+	if r.waiting.Load() {
+		r.Signal()
+	}
+	return true
+}
+
+// Drain hands every committed record to fn in order and releases its space once fn returns.
+// It stops at the first record that is reserved but not yet committed and returns the number
+// of records consumed. The slice passed to fn must not be retained.
+func (r *RingBuffer) Drain(fn func([]byte)) int {
+	size := uint64(len(r.arena))
+	consumed := 0
+	for {
+		tail := r.tail.Load()
+		if tail == r.head.Load() {
+			return consumed
+		}
+		offset := tail & r.mask
+		mark := &r.marks[offset/ringAlign]
+		length := mark.Load()
+		switch length {
+		case 0:
+			return consumed
+		case markPadding:
+			mark.Store(0)
+			r.tail.Store(tail + size - offset)
+		default:
+			fn(r.arena[offset : offset+uint64(length-1)])
+			mark.Store(0)
+			r.tail.Store(tail + alignRecord(uint64(length-1)))
+			consumed++
+		}
+	}
+}
+
+// Wait blocks until a producer commits a record or Signal is called. It returns immediately
+// if the next record is already committed.
+func (r *RingBuffer) Wait() {
+	r.waiting.Store(true)
+	if !r.ready() {
+		<-r.notify
+	}
+	r.waiting.Store(false)
+}
+
+// Signal wakes up the consumer blocked in Wait, if any.
+func (r *RingBuffer) Signal() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (r *RingBuffer) ready() bool {
+	tail := r.tail.Load()
+	return tail != r.head.Load() && r.marks[(tail&r.mask)/ringAlign].Load() != 0
+}
+
+// backoff waits before the given attempt to reserve space in a full arena: it yields first, then
+// sleeps for a doubling duration.
+func backoff(attempt int) {
+	if attempt < ringSpins {
+		runtime.Gosched()
+		return
+	}
+	time.Sleep(min(time.Microsecond<<min(attempt-ringSpins, 10), ringMaxBackoff)) //nolint:mnd
+}
+
+func alignRecord(length uint64) uint64 {
+	if length == 0 {
+		return ringAlign
+	}
+	return (length + ringAlign - 1) &^ (ringAlign - 1)
+}
@@ -0,0 +1,122 @@
+package logutils_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+func TestRingBuffer_PushDrainInOrder(t *testing.T) {
+	ring := logutils.NewRingBuffer(64)
+
+	for _, line := range []string{"first", "", "third line"} {
+		if !ring.Push(nil, []byte(line)) {
+			t.Fatalf("Push(%q) = false, want true", line)
+		}
+	}
+
+	var got []string
+	consumed := ring.Drain(func(b []byte) {
+		got = append(got, string(b))
+	})
+
+	if consumed != 3 || len(got) != 3 || got[0] != "first" || got[1] != "" || got[2] != "third line" {
+		t.Fatalf("Drain() = %d %q, want 3 records in push order", consumed, got)
+	}
+	if ring.Len() != 0 {
+		t.Fatalf("Len() = %d after drain, want 0", ring.Len())
+	}
+}
+
+func TestRingBuffer_RejectsOversizedRecord(t *testing.T) {
+	ring := logutils.NewRingBuffer(32)
+
+	if ring.Push(nil, make([]byte, 33)) {
+		t.Fatal("Push() = true for a record larger than the arena, want false")
+	}
+}
+
+func TestRingBuffer_TryPushFailsWhenFull(t *testing.T) {
+	ring := logutils.NewRingBuffer(32)
+
+	if !ring.TryPush(nil, make([]byte, 16)) || !ring.TryPush(nil, make([]byte, 16)) {
+		t.Fatal("TryPush() = false while there is free space, want true")
+	}
+	if ring.TryPush(nil, []byte("x")) {
+		t.Fatal("TryPush() = true on a full arena, want false")
+	}
+	ring.Drain(func([]byte) {})
+	if !ring.TryPush(nil, []byte("x")) {
+		t.Fatal("TryPush() = false after drain, want true")
+	}
+}
+
+func TestRingBuffer_PushWaitsForSpace(t *testing.T) {
+	ring := logutils.NewRingBuffer(32)
+	ring.Push(nil, make([]byte, 32))
+
+	pushed := make(chan bool)
+	go func() {
+		pushed <- ring.Push(nil, []byte("next"))
+	}()
+	ring.Drain(func([]byte) {})
+
+	if !<-pushed {
+		t.Fatal("Push() = false once space was freed, want true")
+	}
+}
+
+func TestRingBuffer_WrapsAround(t *testing.T) {
+	ring := logutils.NewRingBuffer(64)
+	line := []byte("0123456789abcdefXYZ")
+
+	for i := range 20 {
+		if !ring.Push(nil, line) {
+			t.Fatalf("Push() #%d = false, want true", i)
+		}
+		var got string
+		ring.Drain(func(b []byte) {
+			got = string(b)
+		})
+		if got != string(line) {
+			t.Fatalf("Drain() #%d = %q, want %q", i, got, line)
+		}
+	}
+}
+
+func TestRingBuffer_ConcurrentProducers(t *testing.T) {
+	ring := logutils.NewRingBuffer(256)
+	producers, perProducer := 8, 500
+
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perProducer {
+				ring.Push(fmt.Appendf(nil, "%d-", p), fmt.Appendf(nil, "%d", i))
+			}
+		}()
+	}
+
+	next := make([]int, producers)
+	total := 0
+	for total < producers*perProducer {
+		total += ring.Drain(func(b []byte) {
+			var p, i int
+			if _, err := fmt.Sscanf(string(b), "%d-%d", &p, &i); err != nil {
+				t.Fatalf("corrupted record %q", b)
+			}
+			if i != next[p] {
+				t.Fatalf("producer %d: got record %d, want %d", p, i, next[p])
+			}
+			next[p]++
+		})
+		if total < producers*perProducer {
+			ring.Wait()
+		}
+	}
+	wg.Wait()
+}
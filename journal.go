@@ -0,0 +1,71 @@
+package uslogs
+
+import (
+	"log/slog"
+	"slices"
+)
+
+const (
+	defaultJournalSocket = "/run/systemd/journal/socket"
+	maxJournalFieldName  = 64
+	// journalAttrPrefix is prepended to the attributes named after a field the writer sets itself.
+	journalAttrPrefix = "ATTR_"
+)
+
+// JournalOption represents a function that configures a JournalWriter.
+type JournalOption = func(w *JournalWriter)
+
+// WithJournalSocket sets the path of the journal socket. Defaults to /run/systemd/journal/socket.
+func WithJournalSocket(path string) JournalOption {
+	return func(journalWriter *JournalWriter) {
+		journalWriter.socket = path
+	}
+}
+
+// WithSyslogIdentifier sets the SYSLOG_IDENTIFIER field. Defaults to the program name.
+func WithSyslogIdentifier(identifier string) JournalOption {
+	return func(journalWriter *JournalWriter) {
+		journalWriter.identifier = identifier
+	}
+}
+
+// WithJournalDefaultLevel sets the level used for lines written through Write instead of WriteRecord.
+// Defaults to slog.LevelInfo.
+func WithJournalDefaultLevel(level slog.Level) JournalOption {
+	return func(journalWriter *JournalWriter) {
+		journalWriter.defaultLevel = level
+	}
+}
+
+// appendJournalFieldName appends the given key as a valid journal field name: uppercase ASCII letters,
+// digits and underscores, not starting with an underscore or a digit, and at most 64 characters long. Names of the
+// fields the writer sets itself are prefixed with ATTR_, so an attribute never duplicates them.
+func appendJournalFieldName(dst []byte, key string) []byte {
+	start := len(dst)
+	for idx := range len(key) {
+		char := key[idx]
+		switch {
+		case char >= 'a' && char <= 'z':
+			char -= 'a' - 'A'
+		case char >= 'A' && char <= 'Z':
+		case char >= '0' && char <= '9', char == '_':
+			if len(dst) == start {
+				continue
+			}
+		default:
+			if len(dst) == start {
+				continue
+			}
+			char = '_'
+		}
+		dst = append(dst, char)
+		if len(dst)-start == maxJournalFieldName {
+			break
+		}
+	}
+	switch string(dst[start:]) {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER", "CODE_FILE", "CODE_LINE", "CODE_FUNC":
+		dst = slices.Insert(dst, start, []byte(journalAttrPrefix)...)
+	}
+	return dst
+}
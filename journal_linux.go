@@ -0,0 +1,243 @@
+//go:build linux
+
+package uslogs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+// JournalWriter is a writer that sends every line to systemd-journald using the native journal protocol.
+//
+// When used as the handler writer, the record level is mapped to PRIORITY, attributes become journal fields
+// with uppercased and sanitized names, and CODE_FILE, CODE_LINE and CODE_FUNC come from the record source.
+// Entries too large for a datagram are passed to journald through a sealed memfd, or through an unlinked file in
+// /dev/shm where memfd_create is not available.
+type JournalWriter struct {
+	conn         *net.UnixConn
+	socket       string
+	identifier   string
+	defaultLevel slog.Level
+	mu           sync.Mutex
+}
+
+// NewJournalWriter creates a new JournalWriter instance.
+func NewJournalWriter(opts ...JournalOption) (*JournalWriter, error) {
+	//nolint:exhaustruct
+	journalWriter := &JournalWriter{
+		socket:       defaultJournalSocket,
+		identifier:   programName(),
+		defaultLevel: slog.LevelInfo,
+	}
+	for _, opt := range opts {
+		opt(journalWriter)
+	}
+	//nolint:exhaustruct
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalWriter.socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	journalWriter.conn = conn
+	return journalWriter, nil
+}
+
+// Write sends the given line as the MESSAGE of an entry with the default priority.
+func (w *JournalWriter) Write(input []byte) (int, error) {
+	pool := logutils.BytesPools.GetPool(len(input) + len(w.identifier))
+	buf := pool.Get().(*[]byte) //nolint:forcetypeassert
+	datagram := w.appendHeader((*buf)[:0], w.defaultLevel, bytes.TrimRight(input, "\n"))
+	err := w.send(datagram)
+	*buf = datagram
+	logutils.PutPool(pool, buf)
+	if err != nil {
+		return 0, err
+	}
+	return len(input), nil
+}
+
+// WriteRecord sends the given entry with its attributes and source as journal fields.
+func (w *JournalWriter) WriteRecord(entry *Entry) error {
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	datagram := w.appendHeader((*buf)[:0], entry.Level, bytes.TrimRight(entry.Line, "\n"))
+	if entry.PC != 0 {
+		var lineBuf [20]byte
+		frame, _ := runtime.CallersFrames([]uintptr{entry.PC}).Next()
+		datagram = appendJournalField(datagram, "CODE_FILE", []byte(frame.File))
+		datagram = appendJournalField(datagram, "CODE_LINE", strconv.AppendInt(lineBuf[:0], int64(frame.Line), decimalBase))
+		datagram = appendJournalField(datagram, "CODE_FUNC", []byte(frame.Function))
+	}
+	valueBuf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	value := (*valueBuf)[:0]
+	for _, attr := range entry.Attrs {
+		start := len(datagram)
+		datagram = appendJournalFieldName(datagram, attr.Key)
+		if len(datagram) == start {
+			continue
+		}
+		value = entry.appendMaskedValue(value[:0], attr)
+		datagram = appendJournalValue(datagram, value)
+	}
+	*valueBuf = value
+	logutils.PutPool(logutils.SimplePool, valueBuf)
+	err := w.send(datagram)
+	*buf = datagram
+	logutils.PutPool(logutils.SimplePool, buf)
+	return err
+}
+
+// Close closes the connection to the journal socket.
+func (w *JournalWriter) Close() error {
+	return w.conn.Close() //nolint:wrapcheck
+}
+
+func (w *JournalWriter) appendHeader(dst []byte, level slog.Level, message []byte) []byte {
+	var priority [1]byte
+	priority[0] = '0' + byte(syslogSeverity(level))
+	dst = appendJournalField(dst, "PRIORITY", priority[:])
+	if w.identifier != "" {
+		dst = appendJournalField(dst, "SYSLOG_IDENTIFIER", []byte(w.identifier))
+	}
+	return appendJournalField(dst, "MESSAGE", message)
+}
+
+func (w *JournalWriter) send(datagram []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.conn.Write(datagram)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err //nolint:wrapcheck
+	}
+	return w.sendFile(datagram)
+}
+
+// sendFile passes the entry through a file descriptor, as journald does not accept datagrams above the socket
+// buffer size.
+func (w *JournalWriter) sendFile(datagram []byte) error {
+	file, err := journalMemfd(datagram)
+	if errors.Is(err, syscall.ENOSYS) {
+		file, err = journalTempFile(datagram)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint:errcheck
+	rawConn, err := w.conn.SyscallConn()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	// WriteMsgUnix refuses connected datagram sockets, so the descriptor is sent with a raw sendmsg.
+	rights := syscall.UnixRights(int(file.Fd())) //nolint:gosec
+	var sendErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendmsg(int(fd), nil, rights, nil, 0) //nolint:gosec
+		return !errors.Is(sendErr, syscall.EAGAIN)
+	})
+	return errors.Join(err, sendErr)
+}
+
+// memfdCreateTraps holds the memfd_create system call numbers, which the syscall package only defines for a few
+// architectures. Other architectures fall back to a temporary file.
+//
+//nolint:gochecknoglobals,mnd
+var memfdCreateTraps = map[string]uintptr{
+	"386":     356,
+	"amd64":   319,
+	"arm":     385,
+	"arm64":   279,
+	"loong64": 279,
+	"ppc64":   360,
+	"ppc64le": 360,
+	"riscv64": 279,
+	"s390x":   350,
+}
+
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fcntlAddSeals    = 1033
+	journalFileSeals = 0x1 | 0x2 | 0x4 | 0x8 // F_SEAL_SEAL, F_SEAL_SHRINK, F_SEAL_GROW and F_SEAL_WRITE.
+)
+
+// journalMemfd returns a memfd holding the given entry, sealed so journald can map it without copying. It returns
+// ENOSYS when memfd_create is not available.
+func journalMemfd(datagram []byte) (*os.File, error) {
+	trap, ok := memfdCreateTraps[runtime.GOARCH]
+	if !ok {
+		return nil, syscall.ENOSYS
+	}
+	name, err := syscall.BytePtrFromString("uslogs-journal")
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	file := os.NewFile(fd, "uslogs-journal")
+	if _, err = file.Write(datagram); err != nil {
+		_ = file.Close()
+		return nil, err //nolint:wrapcheck
+	}
+	if _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, fcntlAddSeals, journalFileSeals); errno != 0 {
+		_ = file.Close()
+		return nil, errno
+	}
+	return file, nil
+}
+
+// journalTempFile returns a temporary file holding the given entry. The file is unlinked before sending so it
+// vanishes once journald has read it.
+func journalTempFile(datagram []byte) (*os.File, error) {
+	file, err := os.CreateTemp(journalSpillDir(), "uslogs-journal-")
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if err = os.Remove(file.Name()); err != nil {
+		_ = file.Close()
+		return nil, err //nolint:wrapcheck
+	}
+	if _, err = file.Write(datagram); err != nil {
+		_ = file.Close()
+		return nil, err //nolint:wrapcheck
+	}
+	return file, nil
+}
+
+func journalSpillDir() string {
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		return "/dev/shm"
+	}
+	return os.TempDir()
+}
+
+// appendJournalField appends a field using the simple KEY=value form, or the binary length-prefixed form
+// when the value contains a newline.
+func appendJournalField(dst []byte, name string, value []byte) []byte {
+	return appendJournalValue(append(dst, name...), value)
+}
+
+func appendJournalValue(dst []byte, value []byte) []byte {
+	if bytes.IndexByte(value, '\n') < 0 {
+		dst = append(dst, '=')
+		dst = append(dst, value...)
+		return append(dst, '\n')
+	}
+	dst = append(dst, '\n')
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(len(value)))
+	dst = append(dst, value...)
+	return append(dst, '\n')
+}
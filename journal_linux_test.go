@@ -0,0 +1,183 @@
+//go:build linux
+
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "journal.socket")
+	//nolint:exhaustruct
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, path
+}
+
+// readJournalEntry reads a datagram, following a passed file descriptor if needed, and parses its fields.
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	t.Helper()
+	buf := make([]byte, 1024*1024)
+	oob := make([]byte, 128)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf[:n]
+	if oobn > 0 {
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil || len(msgs) != 1 {
+			t.Fatalf("unexpected control message: %v", err)
+		}
+		fds, err := syscall.ParseUnixRights(&msgs[0])
+		if err != nil || len(fds) != 1 {
+			t.Fatalf("unexpected unix rights: %v", err)
+		}
+		file := os.NewFile(uintptr(fds[0]), "journal-entry")
+		defer file.Close() //nolint:errcheck
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if data, err = io.ReadAll(file); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		line := data[:end]
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(name)] = string(value)
+			data = data[end+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[end+1:])
+		value := data[end+9 : end+9+int(size)]
+		fields[string(line)] = string(value)
+		data = data[end+9+int(size)+1:]
+	}
+	return fields
+}
+
+func TestJournalWriter_WriteRecord(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := uslogs.NewJournalWriter(uslogs.WithJournalSocket(path), uslogs.WithSyslogIdentifier("app"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(w),
+		uslogs.WithMaskedAttributes("password"),
+		uslogs.WithMaskedPatterns(
+			uslogs.MaskPattern{Start: "Bearer ", Delimiters: []byte{']'}},
+			uslogs.MaskPattern{Start: "token=", Delimiters: []byte{' ', '\n'}}))
+	logger := slog.New(handler).With("request-id", "abc").WithGroup("http")
+	logger.Warn("multi\nline", "status", 503, "password", "hunter2", "auth", "[Bearer xyz]", "token", "SECRET")
+
+	fields := readJournalEntry(t, conn)
+	expected := map[string]string{
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "app",
+		"MESSAGE":           "WARN multi\nline request-id=abc http.status=503 http.password=<MASKED> http.auth=[Bearer ***] http.token=******",
+		"REQUEST_ID":        "abc",
+		"HTTP_STATUS":       "503",
+		"HTTP_PASSWORD":     "<MASKED>",
+		"HTTP_AUTH":         "[Bearer ***]",
+		"HTTP_TOKEN":        "******",
+	}
+	for name, value := range expected {
+		if fields[name] != value {
+			t.Errorf("field %s = %q, expected %q", name, fields[name], value)
+		}
+	}
+	if !strings.HasSuffix(fields["CODE_FILE"], "journal_linux_test.go") || fields["CODE_LINE"] == "" {
+		t.Errorf("got source %s:%s, expected this test file", fields["CODE_FILE"], fields["CODE_LINE"])
+	}
+}
+
+func TestJournalWriter_PrefixesReservedFields(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := uslogs.NewJournalWriter(uslogs.WithJournalSocket(path), uslogs.WithSyslogIdentifier("app"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	logger := slog.New(uslogs.NewUnstructuredHandler(uslogs.WithWriter(w)))
+	logger.Info("login", "message", "hello", "priority", "high", "syslog_identifier", "other", "code_line", 7)
+
+	fields := readJournalEntry(t, conn)
+	expected := map[string]string{
+		"PRIORITY":               "6",
+		"SYSLOG_IDENTIFIER":      "app",
+		"MESSAGE":                "INFO login message=hello priority=high syslog_identifier=other code_line=7",
+		"ATTR_MESSAGE":           "hello",
+		"ATTR_PRIORITY":          "high",
+		"ATTR_SYSLOG_IDENTIFIER": "other",
+		"ATTR_CODE_LINE":         "7",
+	}
+	for name, value := range expected {
+		if fields[name] != value {
+			t.Errorf("field %s = %q, expected %q", name, fields[name], value)
+		}
+	}
+}
+
+func TestJournalWriter_Write(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := uslogs.NewJournalWriter(uslogs.WithJournalSocket(path), uslogs.WithJournalDefaultLevel(slog.LevelError))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	if _, err = w.Write([]byte("plain line\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fields := readJournalEntry(t, conn)
+	if fields["MESSAGE"] != "plain line" || fields["PRIORITY"] != "3" {
+		t.Fatalf("got fields %v, expected plain line with priority 3", fields)
+	}
+}
+
+func TestJournalWriter_LargeEntryPassesFileDescriptor(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := uslogs.NewJournalWriter(uslogs.WithJournalSocket(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(w))
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "big", 0)
+	record.AddAttrs(slog.String("payload", strings.Repeat("x", 512*1024)))
+	if err = handler.Handle(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fields := readJournalEntry(t, conn)
+	if len(fields["PAYLOAD"]) != 512*1024 {
+		t.Fatalf("got payload of %d bytes, expected %d", len(fields["PAYLOAD"]), 512*1024)
+	}
+}
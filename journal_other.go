@@ -0,0 +1,38 @@
+//go:build !linux
+
+package uslogs
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// ErrJournalUnsupported is returned by NewJournalWriter on platforms without systemd-journald.
+var ErrJournalUnsupported = errors.New("systemd journal is only supported on linux")
+
+// JournalWriter is a writer that sends every line to systemd-journald. It is only available on linux.
+type JournalWriter struct {
+	socket       string
+	identifier   string
+	defaultLevel slog.Level
+}
+
+// NewJournalWriter returns ErrJournalUnsupported on this platform.
+func NewJournalWriter(_ ...JournalOption) (*JournalWriter, error) {
+	return nil, ErrJournalUnsupported
+}
+
+// Write returns ErrJournalUnsupported on this platform.
+func (w *JournalWriter) Write(_ []byte) (int, error) {
+	return 0, ErrJournalUnsupported
+}
+
+// WriteRecord returns ErrJournalUnsupported on this platform.
+func (w *JournalWriter) WriteRecord(_ *Entry) error {
+	return ErrJournalUnsupported
+}
+
+// Close does nothing on this platform.
+func (w *JournalWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,248 @@
+package uslogs
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const (
+	defaultTimeKey    = "time"
+	defaultLevelKey   = "level"
+	defaultMessageKey = "msg"
+)
+
+// jsonSafe flags the ASCII bytes that can be written in a JSON string as is.
+//
+//nolint:gochecknoglobals
+var jsonSafe = func() [utf8.RuneSelf]bool {
+	var safe [utf8.RuneSelf]bool
+	for char := ' '; char < utf8.RuneSelf; char++ {
+		safe[char] = true
+	}
+	safe['"'] = false
+	safe['\\'] = false
+	return safe
+}()
+
+// appendJSONLine appends the line of the given record as a JSON object, and returns the position of the
+// timestamp in it. contextBytes holds the trace and context attributes, which stay at the top level, and
+// recordBytes the record attributes, which go into the groups of the handler.
+func (l *UnstructuredHandler) appendJSONLine(
+	dst []byte, record slog.Record, handlerAttrs, contextBytes, recordBytes []byte,
+) ([]byte, int, int) {
+	dst = append(dst, '{')
+	timeStart, timeEnd := len(dst), len(dst)
+	if l.withTime {
+		dst = appendJSONString(dst, l.timeKey)
+		dst = append(dst, ':', '"')
+		timeStart = len(dst)
+		dst = logutils.AppendTimeRFC3339(dst, record.Time.UTC())
+		timeEnd = len(dst)
+		dst = append(dst, '"', ',')
+	}
+	dst = appendJSONString(dst, l.levelKey)
+	dst = append(dst, ':')
+	if name, ok := levelNames[record.Level]; ok {
+		dst = append(dst, '"')
+		dst = append(dst, name...)
+		dst = append(dst, '"')
+	} else {
+		dst = appendJSONString(dst, record.Level.String())
+	}
+	dst = append(dst, ',')
+	dst = appendJSONString(dst, l.messageKey)
+	dst = append(dst, ':')
+	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 {
+		dst = appendMaskedField(l, dst, "", record.Message, appendJSONString[[]byte])
+	} else {
+		dst = appendJSONString(dst, record.Message)
+	}
+	if l.sourceKey != "" && record.PC != 0 {
+		dst = append(dst, ',')
+		dst = appendJSONString(dst, l.sourceKey)
+		dst = append(dst, ':')
+		dst = appendJSONString(dst, sourceOf(record.PC))
+	}
+	dst = append(dst, contextBytes...)
+	// Summary lines are written without the handler attributes, so the groups they open are not either.
+	depth := 0
+	if handlerAttrs != nil {
+		dst = append(dst, handlerAttrs...)
+		depth = l.jsonDepth
+	}
+	if len(recordBytes) > 0 {
+		for _, group := range l.jsonGroups {
+			dst = append(dst, ',')
+			dst = appendJSONString(dst, group)
+			dst = append(dst, ':', '{')
+		}
+		depth += len(l.jsonGroups)
+		if dst[len(dst)-1] == '{' {
+			recordBytes = recordBytes[1:]
+		}
+		dst = append(dst, recordBytes...)
+	}
+	for range depth {
+		dst = append(dst, '}')
+	}
+	return append(dst, '}', '\n'), timeStart, timeEnd
+}
+
+// appendJSONAttr appends the attribute as a JSON member preceded by a comma, unless it is the first member of
+// an object. Group values become nested objects, left out when empty and inlined when their key is empty.
+func (l *UnstructuredHandler) appendJSONAttr(dst []byte, attr slog.Attr) []byte {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		groupAttrs := value.Group()
+		if len(groupAttrs) == 0 {
+			return dst
+		}
+		if attr.Key != "" {
+			dst = appendJSONComma(dst)
+			dst = appendJSONString(dst, attr.Key)
+			dst = append(dst, ':', '{')
+		}
+		for _, groupAttr := range groupAttrs {
+			dst = l.appendJSONAttr(dst, groupAttr)
+		}
+		if attr.Key != "" {
+			dst = append(dst, '}')
+		}
+		return dst
+	}
+	dst = appendJSONComma(dst)
+	dst = appendJSONString(dst, attr.Key)
+	dst = append(dst, ':')
+	if slices.Contains(l.maskedAttrs, attr.Key) {
+		return append(dst, `"`+maskedFieldValue+`"`...)
+	}
+	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 {
+		return appendMaskedAttrValue(l, dst, attr.Key, value, appendJSONValue, appendJSONString[[]byte])
+	}
+	return appendJSONValue(dst, value)
+}
+
+// openJSONGroups appends the objects of the groups added with WithGroup since the last attributes, before
+// attributes are added to the handler.
+func (l *UnstructuredHandler) openJSONGroups(dst []byte) []byte {
+	for _, group := range l.jsonGroups {
+		dst = append(dst, ',')
+		dst = appendJSONString(dst, group)
+		dst = append(dst, ':', '{')
+	}
+	l.jsonDepth += len(l.jsonGroups)
+	l.jsonGroups = nil
+	return dst
+}
+
+func appendJSONComma(dst []byte) []byte {
+	if len(dst) > 0 && dst[len(dst)-1] == '{' {
+		return dst
+	}
+	return append(dst, ',')
+}
+
+// appendJSONValue appends the value with the encoding of slog.JSONHandler: durations are written in
+// nanoseconds, times in RFC 3339 and errors as their message. Other values fall back to encoding/json.
+func appendJSONValue(dst []byte, value slog.Value) []byte {
+	//nolint:exhaustive
+	switch value.Kind() {
+	case slog.KindString:
+		return appendJSONString(dst, value.String())
+	case slog.KindInt64:
+		return strconv.AppendInt(dst, value.Int64(), decimalBase)
+	case slog.KindUint64:
+		return strconv.AppendUint(dst, value.Uint64(), decimalBase)
+	case slog.KindFloat64:
+		float := value.Float64()
+		if math.IsNaN(float) || math.IsInf(float, 0) {
+			return appendJSONString(dst, strconv.FormatFloat(float, 'g', -1, 64)) //nolint:mnd
+		}
+		return strconv.AppendFloat(dst, float, 'g', -1, 64) //nolint:mnd
+	case slog.KindBool:
+		return strconv.AppendBool(dst, value.Bool())
+	case slog.KindDuration:
+		return strconv.AppendInt(dst, int64(value.Duration()), decimalBase)
+	case slog.KindTime:
+		dst = append(dst, '"')
+		dst = value.Time().AppendFormat(dst, time.RFC3339Nano)
+		return append(dst, '"')
+	default:
+		return appendJSONAny(dst, value.Any())
+	}
+}
+
+func appendJSONAny(dst []byte, value any) []byte {
+	if err, ok := value.(error); ok {
+		if _, ok := value.(json.Marshaler); !ok {
+			return appendJSONString(dst, err.Error())
+		}
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return appendJSONString(dst, slog.AnyValue(value).String())
+	}
+	return append(dst, encoded...)
+}
+
+// appendJSONString appends the value quoted and escaped. Invalid UTF-8 is replaced by U+FFFD, and U+2028 and
+// U+2029 are escaped as JavaScript does not allow them in strings.
+func appendJSONString[T string | []byte](dst []byte, value T) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for idx := 0; idx < len(value); {
+		char := value[idx]
+		if char < utf8.RuneSelf {
+			if jsonSafe[char] {
+				idx++
+				continue
+			}
+			dst = append(dst, value[start:idx]...)
+			switch char {
+			case '"', '\\':
+				dst = append(dst, '\\', char)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', logutils.HexDigits[char>>4], logutils.HexDigits[char&0x0f]) //nolint:mnd
+			}
+			idx++
+			start = idx
+			continue
+		}
+		char32, size := decodeRune(value[idx:])
+		switch {
+		case char32 == utf8.RuneError && size == 1:
+			dst = append(dst, value[start:idx]...)
+			dst = append(dst, "\ufffd"...)
+		case char32 == '\u2028' || char32 == '\u2029':
+			dst = append(dst, value[start:idx]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', logutils.HexDigits[char32&0x0f])
+		default:
+			idx += size
+			continue
+		}
+		idx += size
+		start = idx
+	}
+	dst = append(dst, value[start:]...)
+	return append(dst, '"')
+}
+
+func decodeRune[T string | []byte](text T) (rune, int) {
+	if bytes, ok := any(text).([]byte); ok {
+		return utf8.DecodeRune(bytes)
+	}
+	return utf8.DecodeRuneInString(string(text))
+}
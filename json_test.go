@@ -0,0 +1,240 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func decodeJSONLines(t *testing.T, output string) []map[string]any {
+	t.Helper()
+	var objects []map[string]any
+	for line := range strings.Lines(output) {
+		var object map[string]any
+		if err := json.Unmarshal([]byte(line), &object); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+func TestUnstructuredHandler_JSONMatchesSlog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+	}{
+		{
+			name: "plain message",
+			log: func(logger *slog.Logger) {
+				logger.Info("hello")
+			},
+		},
+		{
+			name: "escaped values",
+			log: func(logger *slog.Logger) {
+				logger.Warn("quote \" and backslash \\",
+					"multi", "line1\nline2\ttab\r",
+					"control", "\x01\x1f",
+					"unicode", "café ☕",
+					"invalid", "a\xffb",
+					"separator", "a b",
+					"html", "<a href=\"x\">&</a>")
+			},
+		},
+		{
+			name: "scalar kinds",
+			log: func(logger *slog.Logger) {
+				logger.Error("kinds",
+					"int", -42,
+					"uint", uint64(1)<<63,
+					"float", 3.25,
+					"big", 1e21,
+					"bool", false,
+					"duration", 1500*time.Millisecond,
+					"at", time.Date(2025, 1, 2, 3, 4, 5, 600, time.UTC),
+					"err", errors.New("connection reset"),
+					"any", map[string]int{"a": 1},
+					"nil", nil)
+			},
+		},
+		{
+			name: "nested groups",
+			log: func(logger *slog.Logger) {
+				logger.With("service", "api").WithGroup("http").With("method", "GET").WithGroup("req").
+					Info("served", "path", "/", slog.Group("user", "id", 7, slog.Group("role", "name", "admin")))
+			},
+		},
+		{
+			name: "groups without attributes",
+			log: func(logger *slog.Logger) {
+				logger.WithGroup("a").Info("no attrs")
+				logger.WithGroup("a").With("x", 1).WithGroup("b").Info("attrs only in a")
+				logger.Info("empty group value", slog.Group("empty"), "after", 1)
+			},
+		},
+		{
+			name: "inline group",
+			log: func(logger *slog.Logger) {
+				logger.Info("inline", slog.Group("", "a", 1, "b", 2))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got, expected bytes.Buffer
+			test.log(slog.New(uslogs.NewUnstructuredHandler(
+				uslogs.WithWriter(&got),
+				uslogs.WithFormat(uslogs.FormatJSON))))
+			//nolint:exhaustruct
+			test.log(slog.New(slog.NewJSONHandler(&expected, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+					if len(groups) == 0 && attr.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return attr
+				},
+			})))
+
+			gotObjects := decodeJSONLines(t, got.String())
+			expectedObjects := decodeJSONLines(t, expected.String())
+			if !reflect.DeepEqual(gotObjects, expectedObjects) {
+				t.Fatalf("got %q, expected %q", got.String(), expected.String())
+			}
+		})
+	}
+}
+
+func TestUnstructuredHandler_JSONLine(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatJSON),
+		uslogs.WithTimestamp(),
+		uslogs.WithSeparator('|'),
+		uslogs.WithMaskedAttributes("password"),
+	)
+	logger := slog.New(handler).With("service", "api").WithGroup("http")
+
+	record := slog.NewRecord(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), slog.LevelInfo, "login", 0)
+	record.AddAttrs(slog.String("user", "alice"), slog.String("password", "secret"))
+	if err := logger.Handler().Handle(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"time":"2025-01-01T00:00:00Z","level":"INFO","msg":"login","service":"api",` +
+		`"http":{"user":"alice","password":"<MASKED>"}}` + "\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_JSONMaskedPatterns(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatJSON),
+		uslogs.WithMaskedPatterns(
+			uslogs.MaskPattern{Start: "card=", Delimiters: []byte{' ', '\n'}},
+			uslogs.MaskPattern{Start: "token=", Delimiters: []byte{' ', '\n'}}),
+	)).WithGroup("payment")
+
+	logger.Info("charged card=4111 twice", "card", int64(4111111111111111), "token", "SECRET", "amount", 10)
+
+	expected := `{"level":"INFO","msg":"charged card=**** twice",` +
+		`"payment":{"card":"****************","token":"******","amount":10}}` + "\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_JSONKeys(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatJSON),
+		uslogs.WithTimestamp(),
+		uslogs.WithTimeKey("@timestamp"),
+		uslogs.WithLevelKey("severity"),
+		uslogs.WithMessageKey("message"),
+		uslogs.WithSourceKey("caller"),
+	))
+
+	logger.Info("hello")
+
+	objects := decodeJSONLines(t, out.String())
+	if len(objects) != 1 {
+		t.Fatalf("got %d lines, expected 1", len(objects))
+	}
+	object := objects[0]
+	if _, err := time.Parse(time.RFC3339, object["@timestamp"].(string)); err != nil { //nolint:forcetypeassert
+		t.Errorf("invalid timestamp %v: %v", object["@timestamp"], err)
+	}
+	if object["severity"] != "INFO" || object["message"] != "hello" {
+		t.Errorf("unexpected level or message in %v", object)
+	}
+	if caller, _ := object["caller"].(string); !strings.HasPrefix(caller, "json_test.go:") {
+		t.Errorf("got caller %q, expected json_test.go:<line>", caller)
+	}
+	for _, key := range []string{"time", "level", "msg"} {
+		if _, ok := object[key]; ok {
+			t.Errorf("unexpected default key %q in %v", key, object)
+		}
+	}
+}
+
+func TestUnstructuredHandler_JSONContextAttrsOutsideGroups(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatJSON),
+		uslogs.WithTraceContext(uslogs.ContextTraceSource{}),
+		uslogs.WithContextExtractors(uslogs.AppendContextAttrs),
+	)
+	traceParent, err := uslogs.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := uslogs.ContextWithTraceParent(context.Background(), traceParent)
+	ctx = uslogs.ContextWithAttrs(ctx, slog.String("request_id", "r-1"))
+
+	slog.New(handler).WithGroup("http").InfoContext(ctx, "served", "status", 200)
+
+	expected := `{"level":"INFO","msg":"served","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736",` +
+		`"span_id":"00f067aa0ba902b7","request_id":"r-1","http":{"status":200}}` + "\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_JSONSummaryLines(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatJSON),
+		uslogs.WithDeduplicator(uslogs.NewDeduplicator(0)),
+	)
+	logger := slog.New(handler).WithGroup("http").With("method", "GET")
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for range 3 {
+		handleAt(t, logger.Handler(), start, slog.LevelInfo, "retry")
+	}
+	handleAt(t, logger.Handler(), start, slog.LevelInfo, "done")
+
+	objects := decodeJSONLines(t, out.String())
+	if len(objects) != 3 {
+		t.Fatalf("got %d lines %q, expected 3", len(objects), out.String())
+	}
+	if objects[1]["msg"] != "last message repeated 2 times" {
+		t.Errorf("got summary %v", objects[1])
+	}
+}
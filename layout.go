@@ -0,0 +1,174 @@
+package uslogs
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+type layoutToken int
+
+const (
+	layoutLiteral layoutToken = iota
+	layoutTime
+	layoutLevel
+	layoutMessage
+	layoutAttrs
+	layoutSource
+	layoutContext
+)
+
+//nolint:gochecknoglobals
+var layoutTokens = []struct {
+	name  string
+	token layoutToken
+}{
+	{name: "time", token: layoutTime},
+	{name: "level", token: layoutLevel},
+	{name: "msg", token: layoutMessage},
+	{name: "attrs", token: layoutAttrs},
+	{name: "source", token: layoutSource},
+	{name: "ctx", token: layoutContext},
+}
+
+// layoutOp is one step of a compiled layout: a literal to copy or an element of the record to append.
+type layoutOp struct {
+	literal string
+	arg     string
+	token   layoutToken
+}
+
+// compileLayout turns a template into the list of steps appended for every record. Unknown tokens are kept as
+// literals.
+func compileLayout(template string) []layoutOp {
+	var ops []layoutOp
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			ops = append(ops, layoutOp{literal: literal.String(), arg: "", token: layoutLiteral})
+			literal.Reset()
+		}
+	}
+	for idx := 0; idx < len(template); idx++ {
+		if template[idx] != '%' {
+			literal.WriteByte(template[idx])
+			continue
+		}
+		rest := template[idx+1:]
+		if strings.HasPrefix(rest, "%") {
+			literal.WriteByte('%')
+			idx++
+			continue
+		}
+		op, length, ok := parseLayoutToken(rest)
+		if !ok {
+			literal.WriteByte('%')
+			continue
+		}
+		flush()
+		ops = append(ops, op)
+		idx += length
+	}
+	flush()
+	return ops
+}
+
+// parseLayoutToken parses a token name and its optional {argument} at the start of the given text.
+func parseLayoutToken(text string) (layoutOp, int, bool) {
+	for _, candidate := range layoutTokens {
+		if !strings.HasPrefix(text, candidate.name) {
+			continue
+		}
+		op := layoutOp{literal: "", arg: "", token: candidate.token}
+		length := len(candidate.name)
+		if strings.HasPrefix(text[length:], "{") {
+			end := strings.IndexByte(text[length:], '}')
+			if end < 0 {
+				return op, 0, false
+			}
+			op.arg = text[length+1 : length+end]
+			length += end + 1
+		}
+		if op.token == layoutContext && op.arg == "" {
+			return op, 0, false
+		}
+		return op, length, true
+	}
+	return layoutOp{}, 0, false //nolint:exhaustruct
+}
+
+// layoutFields returns the keys of the context attributes placed by the layout, which are left out of %attrs.
+func layoutFields(ops []layoutOp) []string {
+	var fields []string
+	for _, op := range ops {
+		if op.token == layoutContext {
+			fields = append(fields, op.arg)
+		}
+	}
+	return fields
+}
+
+// appendLayout appends the line of the given record following the compiled layout, and returns the position
+// of the timestamp in it.
+func (l *UnstructuredHandler) appendLayout(
+	dst []byte, record slog.Record, handlerAttrs, attrBytes []byte, contextAttrs []slog.Attr,
+) ([]byte, int, int) {
+	lineStart := len(dst)
+	timeStart, timeEnd := len(dst), len(dst)
+	for idx := range l.layout {
+		op := &l.layout[idx]
+		switch op.token {
+		case layoutLiteral:
+			dst = append(dst, op.literal...)
+		case layoutTime:
+			timeStart = len(dst)
+			dst = l.appendTime(dst, record.Time, op.arg)
+			timeEnd = len(dst)
+		case layoutLevel:
+			dst = l.appendLevel(dst, record.Level)
+		case layoutMessage:
+			dst = l.appendMessage(dst, record.Message, lineStart, len(handlerAttrs) > 0 || len(attrBytes) > 0)
+		case layoutAttrs:
+			dst = append(dst, handlerAttrs...)
+			dst = append(dst, attrBytes...)
+		case layoutSource:
+			dst = appendSource(dst, record.PC)
+		case layoutContext:
+			dst = l.appendContextField(dst, contextAttrs, op.arg)
+		}
+	}
+	return l.appendBlock(append(dst, '\n'), record), timeStart, timeEnd
+}
+
+func (l *UnstructuredHandler) appendContextField(dst []byte, contextAttrs []slog.Attr, key string) []byte {
+	for _, attr := range contextAttrs {
+		if attr.Key != key {
+			continue
+		}
+		if slices.Contains(l.maskedAttrs, key) {
+			return append(dst, maskedFieldValue...)
+		}
+		valueStart := len(dst)
+		dst = logutils.AppendValue(dst, attr.Value)
+		if l.multiline != MultilineKeep {
+			dst = l.foldLines(dst, valueStart)
+		}
+		return dst
+	}
+	return dst
+}
+
+// appendSource appends the base name of the file and the line of the given program counter.
+func appendSource(dst []byte, pc uintptr) []byte {
+	if pc == 0 {
+		return dst
+	}
+	return append(dst, sourceOf(pc)...)
+}
+
+// sourceOf returns the base name of the file and the line of the given program counter.
+func sourceOf(pc uintptr) string {
+	return framesOf(pc)[0].source
+}
@@ -0,0 +1,71 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestUnstructuredHandler_Layout(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithSeparator('|'),
+		uslogs.WithLayout("%time{2006-01-02 15:04:05.000} [%level] %msg%attrs 100%%"),
+	).WithAttrs([]slog.Attr{slog.String("service", "api")})
+	record := slog.NewRecord(time.Date(2026, 10, 16, 12, 0, 0, 123e6, time.UTC), slog.LevelInfo, "served", 0)
+	record.AddAttrs(slog.Int("status", 200))
+
+	if err := handler.Handle(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "2026-10-16 12:00:00.123 [INFO] served | service=api | status=200 100%\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_LayoutSourceAndContext(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithContextExtractors(uslogs.AppendContextAttrs),
+		uslogs.WithLayout("%level (%source) <%ctx{request_id}> %msg%attrs %unknown"),
+	))
+	ctx := uslogs.ContextWithAttrs(context.Background(),
+		slog.String("request_id", "r-1"), slog.String("tenant", "acme"))
+
+	logger.InfoContext(ctx, "served")
+
+	pattern := regexp.MustCompile(`^INFO \(layout_test\.go:\d+\) <r-1> served tenant=acme %unknown\n$`)
+	if !pattern.MatchString(out.String()) {
+		t.Fatalf("got %q, expected it to match %v", out.String(), pattern)
+	}
+}
+
+func TestUnstructuredHandler_LayoutDeduplicatesIgnoringTime(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithDeduplicator(uslogs.NewDeduplicator(0)),
+		uslogs.WithLayout("[%level] %time %msg"),
+	)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	handleAt(t, handler, start, slog.LevelInfo, "retrying")
+	handleAt(t, handler, start.Add(time.Second), slog.LevelInfo, "retrying")
+	handleAt(t, handler, start.Add(2*time.Second), slog.LevelInfo, "done")
+
+	expected := "[INFO] 2025-01-01T00:00:00Z retrying\n" +
+		"[INFO] 2025-01-01T00:00:02Z last message repeated 1 times\n" +
+		"[INFO] 2025-01-01T00:00:02Z done\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
@@ -0,0 +1,187 @@
+package uslogs
+
+import (
+	"log/slog"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+// logfmtSpecial flags the bytes that require a value to be quoted: spaces, '=', '"' and control characters.
+// Backslashes do not, but they are escaped within quotes.
+//
+//nolint:gochecknoglobals
+var logfmtSpecial = func() [256]bool {
+	var special [256]bool
+	for char := range ' ' + 1 {
+		special[char] = true
+	}
+	special['='] = true
+	special['"'] = true
+	special[0x7f] = true
+	return special
+}()
+
+// appendLogfmtLine appends the line of the given record as logfmt pairs, and returns the position of the
+// timestamp in it.
+func (l *UnstructuredHandler) appendLogfmtLine(
+	dst []byte, record slog.Record, handlerAttrs, attrBytes []byte,
+) ([]byte, int, int) {
+	timeStart, timeEnd := len(dst), len(dst)
+	if l.withTime {
+		dst = appendLogfmtKey(dst, l.timeKey)
+		dst = append(dst, '=')
+		timeStart = len(dst)
+		dst = logutils.AppendTimeRFC3339(dst, record.Time.UTC())
+		timeEnd = len(dst)
+		dst = append(dst, ' ')
+	}
+	dst = appendLogfmtKey(dst, l.levelKey)
+	dst = append(dst, '=')
+	if name, ok := levelNames[record.Level]; ok {
+		dst = append(dst, name...)
+	} else {
+		dst = appendLogfmtString(dst, record.Level.String())
+	}
+	dst = append(dst, ' ')
+	dst = appendLogfmtKey(dst, l.messageKey)
+	dst = append(dst, '=')
+	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 {
+		dst = appendMaskedField(l, dst, "", record.Message, appendLogfmtText)
+	} else {
+		dst = appendLogfmtString(dst, record.Message)
+	}
+	if l.sourceKey != "" && record.PC != 0 {
+		dst = append(dst, ' ')
+		dst = appendLogfmtKey(dst, l.sourceKey)
+		dst = append(dst, '=')
+		dst = appendLogfmtString(dst, sourceOf(record.PC))
+	}
+	dst = append(dst, handlerAttrs...)
+	dst = append(dst, attrBytes...)
+	return append(dst, '\n'), timeStart, timeEnd
+}
+
+// appendLogfmtAttr appends the attribute as a logfmt pair. Group values are flattened into pairs whose keys are
+// qualified by the group path.
+func (l *UnstructuredHandler) appendLogfmtAttr(dst []byte, group []byte, attr slog.Attr) []byte {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		nested := slices.Clip(group)
+		if len(nested) != 0 {
+			nested = append(nested, l.groupSeparator)
+		}
+		nested = append(nested, attr.Key...)
+		for _, groupAttr := range value.Group() {
+			dst = l.appendLogfmtAttr(dst, nested, groupAttr)
+		}
+		return dst
+	}
+	dst = append(dst, ' ')
+	if len(group) != 0 {
+		dst = appendLogfmtKey(dst, group)
+		dst = append(dst, l.groupSeparator)
+	}
+	dst = appendLogfmtKey(dst, attr.Key)
+	dst = append(dst, '=')
+	if slices.Contains(l.maskedAttrs, attr.Key) {
+		return append(dst, maskedFieldValue...)
+	}
+	if l.partialMasker != nil && len(l.partialMaskPatterns) > 0 {
+		return appendMaskedAttrValue(l, dst, attr.Key, value, appendLogfmtValue, appendLogfmtText)
+	}
+	return appendLogfmtValue(dst, value)
+}
+
+func appendLogfmtValue(dst []byte, value slog.Value) []byte {
+	//nolint:exhaustive
+	switch value.Kind() {
+	case slog.KindString:
+		return appendLogfmtString(dst, value.String())
+	case slog.KindInt64, slog.KindUint64, slog.KindFloat64, slog.KindBool:
+		return logutils.AppendValue(dst, value)
+	default:
+		return appendLogfmtString(dst, value.String())
+	}
+}
+
+func appendLogfmtText(dst []byte, text []byte) []byte {
+	return appendLogfmtString(dst, string(text))
+}
+
+// appendLogfmtKey appends the key, replacing the characters that logfmt does not allow in keys by underscores.
+func appendLogfmtKey[T string | []byte](dst []byte, key T) []byte {
+	if len(key) == 0 {
+		return append(dst, '_')
+	}
+	for idx := range len(key) {
+		char := key[idx]
+		if logfmtSpecial[char] {
+			char = '_'
+		}
+		dst = append(dst, char)
+	}
+	return dst
+}
+
+// appendLogfmtString appends the value as is, or quoted and escaped if it is empty or contains spaces, '=',
+// '"', control characters or invalid UTF-8. As in JSON strings, invalid UTF-8 is replaced by U+FFFD.
+func appendLogfmtString(dst []byte, value string) []byte {
+	if !needsLogfmtQuoting(value) {
+		return append(dst, value...)
+	}
+	dst = append(dst, '"')
+	start := 0
+	for idx := 0; idx < len(value); {
+		char := value[idx]
+		if char >= utf8.RuneSelf {
+			char32, size := utf8.DecodeRuneInString(value[idx:])
+			if char32 == utf8.RuneError && size == 1 {
+				dst = append(dst, value[start:idx]...)
+				dst = append(dst, "\ufffd"...)
+				start = idx + size
+			}
+			idx += size
+			continue
+		}
+		if (!logfmtSpecial[char] || char == ' ' || char == '=') && char != '\\' {
+			idx++
+			continue
+		}
+		dst = append(dst, value[start:idx]...)
+		idx++
+		start = idx
+		switch char {
+		case '"', '\\':
+			dst = append(dst, '\\', char)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			dst = append(dst, '\\', 'u', '0', '0', logutils.HexDigits[char>>4], logutils.HexDigits[char&0x0f]) //nolint:mnd
+		}
+	}
+	dst = append(dst, value[start:]...)
+	return append(dst, '"')
+}
+
+func needsLogfmtQuoting(value string) bool {
+	if value == "" {
+		return true
+	}
+	ascii := true
+	for idx := range len(value) {
+		char := value[idx]
+		if logfmtSpecial[char] {
+			return true
+		}
+		if char >= utf8.RuneSelf {
+			ascii = false
+		}
+	}
+	return !ascii && !utf8.ValidString(value)
+}
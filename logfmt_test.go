@@ -0,0 +1,163 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+type logfmtPair struct {
+	key   string
+	value string
+}
+
+// parseLogfmt parses a logfmt line into its pairs, in order, following the same rules as the Loki parser.
+func parseLogfmt(t *testing.T, line string) []logfmtPair {
+	t.Helper()
+	var pairs []logfmtPair
+	line = strings.TrimSuffix(line, "\n")
+	for len(line) > 0 {
+		line = strings.TrimLeft(line, " ")
+		end := strings.IndexAny(line, "= ")
+		if end < 0 || line[end] != '=' {
+			t.Fatalf("missing value for key in %q", line)
+		}
+		key := line[:end]
+		line = line[end+1:]
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			closing := 1
+			for ; closing < len(line) && line[closing] != '"'; closing++ {
+				if line[closing] == '\\' {
+					closing++
+				}
+			}
+			unquoted, err := strconv.Unquote(line[:closing+1])
+			if err != nil {
+				t.Fatalf("invalid quoted value %q: %v", line[:closing+1], err)
+			}
+			value, line = unquoted, line[closing+1:]
+		} else {
+			end = strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			value, line = line[:end], line[end:]
+		}
+		pairs = append(pairs, logfmtPair{key: key, value: value})
+	}
+	return pairs
+}
+
+func TestUnstructuredHandler_LogfmtRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatLogfmt),
+		uslogs.WithSeparator('|'),
+		uslogs.WithMaskedAttributes("password"),
+	)).With("service", "api").WithGroup("http")
+
+	logger.Info("request \"served\"",
+		"path", "/a b",
+		"empty", "",
+		"eq", "a=b",
+		"multi", "line1\nline2\ttab",
+		"control", "\x01",
+		"backslash", `C:\dir`,
+		"bad key", 1,
+		"unicode", "café",
+		"password", "secret",
+		"elapsed", 1500*time.Millisecond,
+		"err", errors.New("connection reset"),
+		slog.Group("user", "id", 7, "admin", true))
+
+	expected := []logfmtPair{
+		{"level", "INFO"},
+		{"msg", `request "served"`},
+		{"service", "api"},
+		{"http.path", "/a b"},
+		{"http.empty", ""},
+		{"http.eq", "a=b"},
+		{"http.multi", "line1\nline2\ttab"},
+		{"http.control", "\x01"},
+		{"http.backslash", `C:\dir`},
+		{"http.bad_key", "1"},
+		{"http.unicode", "café"},
+		{"http.password", "<MASKED>"},
+		{"http.elapsed", "1.5s"},
+		{"http.err", "connection reset"},
+		{"http.user.id", "7"},
+		{"http.user.admin", "true"},
+	}
+	pairs := parseLogfmt(t, out.String())
+	if len(pairs) != len(expected) {
+		t.Fatalf("got %d pairs %v from %q, expected %d", len(pairs), pairs, out.String(), len(expected))
+	}
+	for idx, pair := range pairs {
+		if pair != expected[idx] {
+			t.Errorf("got pair %v, expected %v", pair, expected[idx])
+		}
+	}
+}
+
+func TestUnstructuredHandler_LogfmtTimeAndLevel(t *testing.T) {
+	var out bytes.Buffer
+	handler := uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatLogfmt),
+		uslogs.WithLevel(slog.LevelDebug-4),
+		uslogs.WithTimestamp(),
+	)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	handleAt(t, handler, start, slog.LevelWarn, "slow")
+	handleAt(t, handler, start, slog.LevelDebug-4, "trace")
+
+	expected := "time=2025-01-01T00:00:00Z level=WARN msg=slow\n" +
+		"time=2025-01-01T00:00:00Z level=DEBUG-4 msg=trace\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_LogfmtMaskedPatterns(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatLogfmt),
+		uslogs.WithMaskedPatterns(uslogs.MaskPattern{Start: "token=", Delimiters: []byte{' ', '\n'}}),
+	))
+
+	logger.Info("refreshed token=abc", "token", "SECRET", "note", "a token=xyz b")
+
+	expected := `level=INFO msg="refreshed token=***" token=****** note="a token=*** b"` + "\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_LogfmtInvalidUTF8(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatLogfmt),
+	))
+
+	logger.Info("bad\xffmsg", "raw", "a\xfe\xffb")
+
+	expected := "level=INFO msg=\"bad�msg\" raw=\"a��b\"\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+	if !utf8.Valid(out.Bytes()) {
+		t.Fatalf("got %q, expected valid UTF-8", out.String())
+	}
+}
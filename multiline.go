@@ -0,0 +1,117 @@
+package uslogs
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const defaultContinuationMarker = "\t"
+
+// Multiline represents how line breaks inside messages and attribute values are written in FormatText lines.
+type Multiline int
+
+const (
+	// MultilineKeep writes line breaks as they are. It is the default.
+	MultilineKeep Multiline = iota
+	// MultilineEscape writes line breaks as \n and \r, so every record stays on a single line.
+	MultilineEscape
+	// MultilineIndent starts every continuation line with the continuation marker, so collectors can join
+	// the lines that start with it to the previous one. Stack traces of error attributes follow the line as an
+	// indented block.
+	MultilineIndent
+	// MultilineBlock keeps the first line of the message on the line and writes the rest of it after the line
+	// as a block of lines starting with the continuation marker, followed by the stack traces of error
+	// attributes. Line breaks in attribute values are escaped as with MultilineEscape.
+	MultilineBlock
+)
+
+// foldLines rewrites the line breaks appended to dst since start according to the multi-line policy.
+func (l *UnstructuredHandler) foldLines(dst []byte, start int) []byte {
+	first := bytes.IndexAny(dst[start:], "\r\n")
+	if first < 0 {
+		return dst
+	}
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	segment := append((*buf)[:0], dst[start+first:]...)
+	dst = dst[:start+first]
+	for _, char := range segment {
+		switch {
+		case char == '\n' && l.multiline == MultilineIndent:
+			dst = append(dst, '\n')
+			dst = append(dst, l.continuationMarker...)
+		case char == '\n':
+			dst = append(dst, '\\', 'n')
+		case char == '\r' && l.multiline != MultilineIndent:
+			dst = append(dst, '\\', 'r')
+		default:
+			dst = append(dst, char)
+		}
+	}
+	*buf = segment
+	logutils.PutPool(logutils.SimplePool, buf)
+	return dst
+}
+
+// appendBlock appends the block written after the line of the given record: the rest of a multi-line message
+// with MultilineBlock, then the stack traces of the error attributes of the record.
+func (l *UnstructuredHandler) appendBlock(dst []byte, record slog.Record) []byte {
+	if l.multiline != MultilineIndent && l.multiline != MultilineBlock {
+		return dst
+	}
+	if l.multiline == MultilineBlock {
+		if _, rest, ok := strings.Cut(record.Message, "\n"); ok {
+			dst = l.appendBlockLines(dst, rest)
+		}
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		dst = l.appendStackBlock(dst, attr.Value)
+		return true
+	})
+	return dst
+}
+
+// appendStackBlock appends the stack trace of the given value if it is an error that prints one with the %+v
+// verb, as the errors of github.com/pkg/errors do.
+func (l *UnstructuredHandler) appendStackBlock(dst []byte, value slog.Value) []byte {
+	value = value.Resolve()
+	switch value.Kind() { //nolint:exhaustive
+	case slog.KindGroup:
+		for _, attr := range value.Group() {
+			dst = l.appendStackBlock(dst, attr.Value)
+		}
+		return dst
+	case slog.KindAny:
+	default:
+		return dst
+	}
+	err, ok := value.Any().(error)
+	if !ok {
+		return dst
+	}
+	if _, ok = err.(fmt.Formatter); !ok {
+		return dst
+	}
+	detail := fmt.Sprintf("%+v", err)
+	// The first line usually repeats the message, which is already on the line.
+	if rest, found := strings.CutPrefix(detail, err.Error()+"\n"); found {
+		detail = rest
+	} else if !strings.Contains(detail, "\n") {
+		return dst
+	}
+	return l.appendBlockLines(dst, detail)
+}
+
+// appendBlockLines appends every line of the given text preceded by the continuation marker.
+func (l *UnstructuredHandler) appendBlockLines(dst []byte, text string) []byte {
+	for line := range strings.Lines(text) {
+		line = strings.TrimRight(line, "\r\n")
+		dst = append(dst, l.continuationMarker...)
+		dst = append(dst, line...)
+		dst = append(dst, '\n')
+	}
+	return dst
+}
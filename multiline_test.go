@@ -0,0 +1,109 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+// stackError prints its stack trace with the %+v verb, as the errors of github.com/pkg/errors do.
+type stackError struct {
+	message string
+}
+
+func (e *stackError) Error() string {
+	return e.message
+}
+
+func (e *stackError) Format(state fmt.State, verb rune) {
+	_, _ = io.WriteString(state, e.message)
+	if verb == 'v' && state.Flag('+') {
+		_, _ = io.WriteString(state, "\nmain.query\n\t/app/db.go:42\nmain.main\n\t/app/main.go:7")
+	}
+}
+
+func logMultiline(policy uslogs.Multiline, marker string, opts ...uslogs.LogWriterOption) string {
+	var out bytes.Buffer
+	opts = append(opts, uslogs.WithWriter(&out), uslogs.WithMultiline(policy, marker))
+	slog.New(uslogs.NewUnstructuredHandler(opts...)).Error("query failed\nafter 3 attempts",
+		"query", "SELECT *\r\nFROM t",
+		"err", &stackError{message: "connection reset"},
+		"plain", errors.New("plain\nerror"))
+	return out.String()
+}
+
+func TestUnstructuredHandler_MultilinePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   uslogs.Multiline
+		marker   string
+		expected string
+	}{
+		{
+			name:   "keep",
+			policy: uslogs.MultilineKeep,
+			expected: "ERROR query failed\nafter 3 attempts query=SELECT *\r\nFROM t err=connection reset " +
+				"plain=plain\nerror\n",
+		},
+		{
+			name:   "escape",
+			policy: uslogs.MultilineEscape,
+			expected: `ERROR query failed\nafter 3 attempts query=SELECT *\r\nFROM t err=connection reset ` +
+				`plain=plain\nerror` + "\n",
+		},
+		{
+			name:   "indent",
+			policy: uslogs.MultilineIndent,
+			marker: "  | ",
+			expected: "ERROR query failed\n  | after 3 attempts query=SELECT *\r\n  | FROM t err=connection reset " +
+				"plain=plain\n  | error\n" +
+				"  | main.query\n  | \t/app/db.go:42\n  | main.main\n  | \t/app/main.go:7\n",
+		},
+		{
+			name:   "block",
+			policy: uslogs.MultilineBlock,
+			expected: `ERROR query failed query=SELECT *\r\nFROM t err=connection reset plain=plain\nerror` + "\n" +
+				"\tafter 3 attempts\n" +
+				"\tmain.query\n\t\t/app/db.go:42\n\tmain.main\n\t\t/app/main.go:7\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := logMultiline(test.policy, test.marker); got != test.expected {
+				t.Fatalf("got %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestUnstructuredHandler_MultilineWithLayoutAndWrap(t *testing.T) {
+	got := logMultiline(uslogs.MultilineEscape, "",
+		uslogs.WithLayout("[%level] %msg"),
+		uslogs.WithMessageWidth(12, uslogs.OverflowWrap))
+
+	expected := "[ERROR] query\n" + `        failed\nafte` + "\n" + "        r 3 attempts\n"
+	if got != expected {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestUnstructuredHandler_MultilineGroupedErrorStack(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithMultiline(uslogs.MultilineIndent, ""),
+	))
+
+	logger.Warn("retrying", slog.Group("db", slog.Any("err", &stackError{message: "timeout"})))
+
+	expected := "WARN retrying db=[err=timeout]\n" +
+		"\tmain.query\n\t\t/app/db.go:42\n\tmain.main\n\t\t/app/main.go:7\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
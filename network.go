@@ -0,0 +1,444 @@
+package uslogs
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultNetworkMinBackoff   = 100 * time.Millisecond
+	defaultNetworkMaxBackoff   = 30 * time.Second
+	defaultNetworkKeepAlive    = 30 * time.Second
+	networkDialTimeout         = 5 * time.Second
+	defaultNetworkWriteTimeout = 5 * time.Second
+	spoolFileName              = "uslogs.spool"
+	spoolOffsetFileName        = "uslogs.spool.offset"
+	spoolOffsetSize            = 8
+	spoolRecordHeaderSize      = 4
+	networkTLS                 = "tcp+tls"
+)
+
+var (
+	// ErrNetworkUnavailable is returned by NetworkWriter when the remote is unreachable and there is no spool.
+	ErrNetworkUnavailable = errors.New("network destination unavailable")
+	// ErrSpoolFull is returned by NetworkWriter when the remote is unreachable and the spool reached its size cap.
+	ErrSpoolFull = errors.New("network spool is full")
+)
+
+// NetworkOption represents a function that configures a NetworkWriter.
+type NetworkOption = func(w *NetworkWriter)
+
+// WithNetworkTLS sets the TLS configuration used by the "tcp+tls" network.
+func WithNetworkTLS(config *tls.Config) NetworkOption {
+	return func(networkWriter *NetworkWriter) {
+		networkWriter.tlsConfig = config
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive period. Defaults to 30 seconds.
+func WithKeepAlive(period time.Duration) NetworkOption {
+	return func(networkWriter *NetworkWriter) {
+		networkWriter.keepAlive = period
+	}
+}
+
+// WithWriteTimeout sets how long a line may take to be sent before the connection is considered stalled and
+// dropped. Defaults to 5 seconds.
+func WithWriteTimeout(timeout time.Duration) NetworkOption {
+	return func(networkWriter *NetworkWriter) {
+		networkWriter.writeTimeout = timeout
+	}
+}
+
+// WithBackoff sets the minimum and maximum delay between reconnection attempts. The delay doubles after every
+// failed attempt and is randomized between half and all of its value. Defaults to 100ms and 30s.
+func WithBackoff(minDelay, maxDelay time.Duration) NetworkOption {
+	return func(networkWriter *NetworkWriter) {
+		networkWriter.minBackoff = minDelay
+		networkWriter.maxBackoff = maxDelay
+	}
+}
+
+// WithSpool keeps the lines written while the remote is unreachable in a file inside the given directory and
+// replays them in order once it comes back. The spool never grows beyond maxBytes; lines that do not fit are
+// dropped with ErrSpoolFull. The replay position is saved next to the spool after every line, so a restart
+// resumes where the previous process stopped instead of sending the replayed lines again.
+func WithSpool(dir string, maxBytes int64) NetworkOption {
+	return func(networkWriter *NetworkWriter) {
+		networkWriter.spoolDir = dir
+		networkWriter.spoolMax = maxBytes
+	}
+}
+
+// NetworkWriter is a writer that ships lines to a remote collector over "tcp", "tcp+tls" or "udp".
+//
+// When the connection fails, it reconnects in the background with an exponential backoff with jitter. With a
+// spool, lines written meanwhile are kept on disk and replayed in order before any new line; without one,
+// they are dropped with ErrNetworkUnavailable. A line that was only partly sent is never sent again, as the
+// remote already received its beginning; the connection is dropped instead. It is safe for concurrent use and
+// can be wrapped by an AsyncWriter.
+type NetworkWriter struct {
+	conn         net.Conn
+	ctx          context.Context //nolint:containedctx
+	cancel       context.CancelFunc
+	tlsConfig    *tls.Config
+	spool        *os.File
+	spoolOffset  *os.File
+	wake         chan struct{}
+	done         chan struct{}
+	nextDial     time.Time
+	network      string
+	address      string
+	spoolDir     string
+	spoolMax     int64
+	spoolSize    int64
+	replayed     int64
+	backoff      time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	keepAlive    time.Duration
+	writeTimeout time.Duration
+	mu           sync.Mutex
+	wg           sync.WaitGroup
+	closed       bool
+}
+
+// NewNetworkWriter creates a new NetworkWriter instance for the given network and address. The remote does
+// not need to be reachable yet. Lines left in the spool by a previous process are replayed once it is.
+func NewNetworkWriter(network, address string, opts ...NetworkOption) (*NetworkWriter, error) {
+	//nolint:exhaustruct
+	networkWriter := &NetworkWriter{
+		network:      network,
+		address:      address,
+		minBackoff:   defaultNetworkMinBackoff,
+		maxBackoff:   defaultNetworkMaxBackoff,
+		keepAlive:    defaultNetworkKeepAlive,
+		writeTimeout: defaultNetworkWriteTimeout,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(networkWriter)
+	}
+	networkWriter.backoff = networkWriter.minBackoff
+	if networkWriter.spoolDir != "" {
+		if err := networkWriter.openSpool(); err != nil {
+			return nil, err
+		}
+	}
+	networkWriter.ctx, networkWriter.cancel = context.WithCancel(context.Background())
+	if conn, err := networkWriter.dial(); err != nil {
+		networkWriter.scheduleRetry()
+	} else {
+		networkWriter.conn = conn
+	}
+	networkWriter.wg.Add(1)
+	go networkWriter.reconnectLoop()
+	return networkWriter, nil
+}
+
+// Write sends the given line to the remote, or spools it while the remote is unreachable.
+func (w *NetworkWriter) Write(input []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	// Lines must not overtake the spool, so anything pending is replayed first.
+	if w.conn != nil && w.spoolPending() {
+		w.replay()
+	}
+	if w.conn != nil && !w.spoolPending() {
+		numBytes, err := w.send(input)
+		if err == nil || numBytes > 0 {
+			return numBytes, err
+		}
+	}
+	if w.spool == nil {
+		return 0, ErrNetworkUnavailable
+	}
+	if err := w.appendSpool(input); err != nil {
+		return 0, err
+	}
+	return len(input), nil
+}
+
+// Close stops reconnecting, closes the connection and the spool. Lines still in the spool are kept on disk
+// and replayed by the next NetworkWriter using the same directory.
+func (w *NetworkWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.cancel()
+	w.mu.Unlock()
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if w.conn != nil {
+		err = w.conn.Close()
+		w.conn = nil
+	}
+	if w.spool != nil {
+		err = errors.Join(err, w.spool.Close(), w.spoolOffset.Close())
+	}
+	return err
+}
+
+// reconnectLoop reconnects in the background when the spool has lines waiting, so they are replayed even if
+// nothing else is written.
+func (w *NetworkWriter) reconnectLoop() {
+	defer w.wg.Done()
+	timer := time.NewTimer(w.minBackoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.wake:
+		case <-timer.C:
+		}
+		if !w.reconnect() {
+			return
+		}
+		w.mu.Lock()
+		if w.conn != nil && w.spoolPending() {
+			w.replay()
+		}
+		wait := w.minBackoff
+		if w.conn == nil {
+			wait = max(time.Until(w.nextDial), 0)
+		}
+		w.mu.Unlock()
+		timer.Reset(wait)
+	}
+}
+
+// reconnect dials the remote when the connection is down and the backoff elapsed. The dial runs without the
+// lock, so Write keeps spooling or failing fast meanwhile. It reports false once the writer is closed.
+func (w *NetworkWriter) reconnect() bool {
+	w.mu.Lock()
+	due := w.conn == nil && !time.Now().Before(w.nextDial)
+	w.mu.Unlock()
+	if !due {
+		return true
+	}
+	conn, err := w.dial()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		if err == nil {
+			_ = conn.Close()
+		}
+		return false
+	}
+	if err != nil {
+		w.scheduleRetry()
+		return true
+	}
+	w.conn = conn
+	w.backoff = w.minBackoff
+	return true
+}
+
+// dial connects to the remote. Close cancels a dial in progress.
+func (w *NetworkWriter) dial() (net.Conn, error) {
+	//nolint:exhaustruct
+	dialer := &net.Dialer{Timeout: networkDialTimeout, KeepAlive: w.keepAlive}
+	if w.network == networkTLS {
+		//nolint:exhaustruct
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: w.tlsConfig}
+		return tlsDialer.DialContext(w.ctx, "tcp", w.address) //nolint:wrapcheck
+	}
+	return dialer.DialContext(w.ctx, w.network, w.address) //nolint:wrapcheck
+}
+
+// send writes the record within the write timeout, so a stalled remote cannot block Write and Close forever.
+// On failure the connection is dropped, and the number of bytes sent tells whether the remote already received
+// part of the record.
+func (w *NetworkWriter) send(record []byte) (int, error) {
+	if w.writeTimeout > 0 {
+		_ = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+	numBytes, err := w.conn.Write(record)
+	if err != nil {
+		w.disconnect()
+	}
+	return numBytes, err //nolint:wrapcheck
+}
+
+func (w *NetworkWriter) disconnect() {
+	_ = w.conn.Close()
+	w.conn = nil
+	w.scheduleRetry()
+}
+
+// scheduleRetry sets the next reconnection attempt using the current backoff with jitter, then doubles it.
+func (w *NetworkWriter) scheduleRetry() {
+	half := w.backoff / 2                              //nolint:mnd
+	w.nextDial = time.Now().Add(half + rand.N(half+1)) //nolint:gosec
+	w.backoff = min(w.backoff*2, w.maxBackoff)         //nolint:mnd
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// openSpool opens the spool and the saved replay position, and drops a record left incomplete by a crash
+// while it was appended.
+func (w *NetworkWriter) openSpool() error {
+	if err := os.MkdirAll(w.spoolDir, defaultDirMode); err != nil {
+		return err //nolint:wrapcheck
+	}
+	file, err := os.OpenFile(filepath.Join(w.spoolDir, spoolFileName), os.O_CREATE|os.O_RDWR, defaultFileMode)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	offsetFile, err := os.OpenFile(
+		filepath.Join(w.spoolDir, spoolOffsetFileName), os.O_CREATE|os.O_RDWR, defaultFileMode)
+	if err != nil {
+		_ = file.Close()
+		return err //nolint:wrapcheck
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		_ = offsetFile.Close()
+		return err //nolint:wrapcheck
+	}
+	w.spool = file
+	w.spoolOffset = offsetFile
+	var offset [spoolOffsetSize]byte
+	if numBytes, _ := offsetFile.ReadAt(offset[:], 0); numBytes == spoolOffsetSize {
+		w.replayed = int64(binary.LittleEndian.Uint64(offset[:])) //nolint:gosec
+	}
+	// A position beyond the spool belongs to a spool truncated before the position was reset.
+	if w.replayed < 0 || w.replayed > info.Size() {
+		w.replayed = 0
+	}
+	w.spoolSize = w.completeSize(info.Size())
+	if w.spoolSize < info.Size() {
+		_, _ = fmt.Fprintf(os.Stderr, "networkWriter spool error: dropping %d bytes of an incomplete record\n",
+			info.Size()-w.spoolSize)
+		if err = file.Truncate(w.spoolSize); err != nil {
+			_ = file.Close()
+			_ = offsetFile.Close()
+			return err //nolint:wrapcheck
+		}
+	}
+	return nil
+}
+
+// completeSize returns the end of the last complete record of a spool of the given size, from the replay
+// position.
+func (w *NetworkWriter) completeSize(size int64) int64 {
+	end := w.replayed
+	var header [spoolRecordHeaderSize]byte
+	for end+spoolRecordHeaderSize <= size {
+		if _, err := w.spool.ReadAt(header[:], end); err != nil {
+			break
+		}
+		next := end + spoolRecordHeaderSize + int64(binary.LittleEndian.Uint32(header[:]))
+		if next > size {
+			break
+		}
+		end = next
+	}
+	return end
+}
+
+func (w *NetworkWriter) spoolPending() bool {
+	return w.spool != nil && w.replayed < w.spoolSize
+}
+
+// appendSpool stores the line as a length-prefixed record so datagram boundaries survive the replay.
+func (w *NetworkWriter) appendSpool(input []byte) error {
+	recordSize := int64(spoolRecordHeaderSize + len(input))
+	if w.spoolMax > 0 && w.spoolSize+recordSize > w.spoolMax {
+		return ErrSpoolFull
+	}
+	var header [spoolRecordHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(input))) //nolint:gosec
+	_, err := w.spool.WriteAt(header[:], w.spoolSize)
+	if err == nil {
+		_, err = w.spool.WriteAt(input, w.spoolSize+spoolRecordHeaderSize)
+	}
+	if err != nil {
+		// A partly written record would be replayed as garbage, so it is cut off.
+		_ = w.spool.Truncate(w.spoolSize)
+		return err //nolint:wrapcheck
+	}
+	w.spoolSize += recordSize
+	return nil
+}
+
+// replay sends the spooled records in order, saving the replay position after each one. It stops at the first
+// failure and resumes from there on the next attempt; once everything was sent, the spool is truncated.
+func (w *NetworkWriter) replay() {
+	reader := bufio.NewReader(io.NewSectionReader(w.spool, w.replayed, w.spoolSize-w.replayed))
+	var header [spoolRecordHeaderSize]byte
+	var record []byte
+	for w.replayed < w.spoolSize {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			w.reportSpoolError(err)
+			break
+		}
+		size := binary.LittleEndian.Uint32(header[:])
+		if uint32(cap(record)) < size { //nolint:gosec
+			record = make([]byte, size)
+		}
+		record = record[:size]
+		if _, err := io.ReadFull(reader, record); err != nil {
+			w.reportSpoolError(err)
+			break
+		}
+		numBytes, err := w.send(record)
+		if err != nil && numBytes == 0 {
+			return
+		}
+		w.replayed += int64(spoolRecordHeaderSize) + int64(size)
+		w.saveOffset()
+		if err != nil {
+			return
+		}
+	}
+	if err := w.spool.Truncate(0); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "networkWriter spool error: %v\n", err)
+		return
+	}
+	w.spoolSize = 0
+	w.replayed = 0
+	w.saveOffset()
+}
+
+// saveOffset persists the replay position, so a restart does not send the replayed records again.
+func (w *NetworkWriter) saveOffset() {
+	var offset [spoolOffsetSize]byte
+	binary.LittleEndian.PutUint64(offset[:], uint64(w.replayed)) //nolint:gosec
+	if _, err := w.spoolOffset.WriteAt(offset[:], 0); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "networkWriter spool error: %v\n", err)
+	}
+}
+
+// reportSpoolError drops the records from the replay position on, which can no longer be read, as replaying
+// them would block every new line. The records before it were already sent.
+func (w *NetworkWriter) reportSpoolError(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "networkWriter spool error: %v\n", err)
+	w.spoolSize = w.replayed
+}
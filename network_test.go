@@ -0,0 +1,304 @@
+package uslogs_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+// collectLines accepts connections one after another and sends every line received on the channel.
+func collectLines(ln net.Listener, lines chan<- string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		_ = conn.Close()
+	}
+}
+
+func expectLines(t *testing.T, lines <-chan string, expected ...string) {
+	t.Helper()
+	for _, want := range expected {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Fatalf("got line %q, expected %q", got, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for line %q", want)
+		}
+	}
+}
+
+func TestNetworkWriter_WritesLines(t *testing.T) {
+	//nolint:noctx
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	lines := make(chan string, 10)
+	go collectLines(ln, lines)
+
+	w, err := uslogs.NewNetworkWriter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	_, _ = w.Write([]byte("one\n"))
+	_, _ = w.Write([]byte("two\n"))
+
+	expectLines(t, lines, "one", "two")
+}
+
+func TestNetworkWriter_UnavailableWithoutSpool(t *testing.T) {
+	w, err := uslogs.NewNetworkWriter("tcp", "127.0.0.1:1", uslogs.WithBackoff(time.Hour, time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	if _, err = w.Write([]byte("lost\n")); !errors.Is(err, uslogs.ErrNetworkUnavailable) {
+		t.Fatalf("got error %v, expected %v", err, uslogs.ErrNetworkUnavailable)
+	}
+}
+
+func TestNetworkWriter_SpoolsAndReplaysInOrder(t *testing.T) {
+	//nolint:noctx
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := probe.Addr().String()
+	_ = probe.Close()
+
+	w, err := uslogs.NewNetworkWriter("tcp", address,
+		uslogs.WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+		uslogs.WithSpool(t.TempDir(), 1024))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err = w.Write([]byte(line)); err != nil {
+			t.Fatalf("unexpected error while spooling: %v", err)
+		}
+	}
+
+	//nolint:noctx
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	lines := make(chan string, 10)
+	go collectLines(ln, lines)
+
+	expectLines(t, lines, "first", "second", "third")
+	_, _ = w.Write([]byte("fourth\n"))
+	expectLines(t, lines, "fourth")
+}
+
+func TestNetworkWriter_SpoolSizeCap(t *testing.T) {
+	w, err := uslogs.NewNetworkWriter("tcp", "127.0.0.1:1",
+		uslogs.WithBackoff(time.Hour, time.Hour),
+		uslogs.WithSpool(t.TempDir(), 16))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	if _, err = w.Write([]byte("fits\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = w.Write([]byte(strings.Repeat("x", 16))); !errors.Is(err, uslogs.ErrSpoolFull) {
+		t.Fatalf("got error %v, expected %v", err, uslogs.ErrSpoolFull)
+	}
+}
+
+func TestNetworkWriter_SpoolSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	//nolint:noctx
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := probe.Addr().String()
+	_ = probe.Close()
+
+	w, err := uslogs.NewNetworkWriter("tcp", address, uslogs.WithSpool(dir, 1024))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _ = w.Write([]byte("kept\n"))
+	_ = w.Close()
+
+	//nolint:noctx
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	lines := make(chan string, 10)
+	go collectLines(ln, lines)
+
+	w, err = uslogs.NewNetworkWriter("tcp", address, uslogs.WithSpool(dir, 1024))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	expectLines(t, lines, "kept")
+}
+
+func TestNetworkWriter_StalledRemoteTimesOut(t *testing.T) {
+	//nolint:noctx
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	// The remote accepts the connection but never reads from it.
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	w, err := uslogs.NewNetworkWriter("tcp", ln.Addr().String(), uslogs.WithWriteTimeout(50*time.Millisecond),
+		uslogs.WithBackoff(time.Hour, time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	line := []byte(strings.Repeat("x", 1<<20) + "\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for err == nil && time.Now().Before(deadline) {
+		_, err = w.Write(line)
+	}
+	if err == nil {
+		t.Fatal("expected a write to time out against a stalled remote")
+	}
+	if _, err = w.Write([]byte("next\n")); !errors.Is(err, uslogs.ErrNetworkUnavailable) {
+		t.Fatalf("got error %v, expected the stalled connection to be dropped", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case conn := <-accepted:
+		_ = conn.Close()
+	default:
+	}
+}
+
+// spoolRecords encodes the given lines as spool records, each prefixed by its little-endian length.
+func spoolRecords(lines ...string) []byte {
+	var spool []byte
+	for _, line := range lines {
+		spool = binary.LittleEndian.AppendUint32(spool, uint32(len(line))) //nolint:gosec
+		spool = append(spool, line...)
+	}
+	return spool
+}
+
+func TestNetworkWriter_SpoolResumesFromSavedPosition(t *testing.T) {
+	dir := t.TempDir()
+	spool := spoolRecords("sent\n", "pending\n")
+	if err := os.WriteFile(filepath.Join(dir, "uslogs.spool"), spool, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	offset := binary.LittleEndian.AppendUint64(nil, uint64(len(spoolRecords("sent\n"))))
+	if err := os.WriteFile(filepath.Join(dir, "uslogs.spool.offset"), offset, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	//nolint:noctx
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	lines := make(chan string, 10)
+	go collectLines(ln, lines)
+
+	w, err := uslogs.NewNetworkWriter("tcp", ln.Addr().String(), uslogs.WithSpool(dir, 1024))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+	_, _ = w.Write([]byte("new\n"))
+
+	expectLines(t, lines, "pending", "new")
+}
+
+func TestNetworkWriter_SpoolDropsIncompleteRecord(t *testing.T) {
+	dir := t.TempDir()
+	torn := append(spoolRecords("kept\n"), spoolRecords("torn record\n")[:8]...)
+	if err := os.WriteFile(filepath.Join(dir, "uslogs.spool"), torn, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	//nolint:noctx
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := probe.Addr().String()
+	_ = probe.Close()
+
+	w, err := uslogs.NewNetworkWriter("tcp", address,
+		uslogs.WithBackoff(10*time.Millisecond, 20*time.Millisecond), uslogs.WithSpool(dir, 1024))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+	if _, err = w.Write([]byte("after\n")); err != nil {
+		t.Fatalf("unexpected error while spooling: %v", err)
+	}
+
+	//nolint:noctx
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+	lines := make(chan string, 10)
+	go collectLines(ln, lines)
+
+	expectLines(t, lines, "kept", "after")
+}
+
+func TestNetworkWriter_WriteDoesNotWaitForDial(t *testing.T) {
+	// A non-routable address makes the dial hang until its timeout.
+	w, err := uslogs.NewNetworkWriter("tcp", "10.255.255.1:9",
+		uslogs.WithBackoff(time.Millisecond, time.Millisecond), uslogs.WithSpool(t.TempDir(), 1024))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if _, err = w.Write([]byte("spooled\n")); err != nil {
+		t.Fatalf("unexpected error while spooling: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("took %v to write and close, expected not to wait for the dial", elapsed)
+	}
+}
@@ -0,0 +1,196 @@
+package uslogs
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultSummaryInterval = time.Second
+
+// RateLimiterOption represents a function that configures a RateLimiter.
+type RateLimiterOption = func(r *RateLimiter)
+
+// WithLineRate limits the lines written per second, allowing bursts of up to burst lines.
+func WithLineRate(linesPerSecond float64, burst int) RateLimiterOption {
+	return func(limiter *RateLimiter) {
+		limiter.lines = newTokenBucket(linesPerSecond, burst)
+	}
+}
+
+// WithByteRate limits the bytes written per second, allowing bursts of up to burst bytes. A line longer than
+// the burst is accepted when the bucket is full.
+func WithByteRate(bytesPerSecond float64, burst int) RateLimiterOption {
+	return func(limiter *RateLimiter) {
+		limiter.bytes = newTokenBucket(bytesPerSecond, burst)
+	}
+}
+
+// WithRateExemption sets the lowest level that is never limited. Defaults to slog.LevelError.
+func WithRateExemption(level slog.Level) RateLimiterOption {
+	return func(limiter *RateLimiter) {
+		limiter.exemptLevel = level
+	}
+}
+
+// WithSummaryInterval sets the minimum time between two summaries of suppressed lines. Defaults to one second.
+func WithSummaryInterval(interval time.Duration) RateLimiterOption {
+	return func(limiter *RateLimiter) {
+		limiter.summaryInterval = interval
+	}
+}
+
+// WithRateClock sets the clock the token buckets are refilled with. Defaults to time.Now; record timestamps are
+// never used, as they may be out of order or set by the caller.
+func WithRateClock(now func() time.Time) RateLimiterOption {
+	return func(limiter *RateLimiter) {
+		limiter.now = now
+	}
+}
+
+// RateLimiter puts a hard ceiling on the lines and bytes written per second using token buckets.
+//
+// Lines at or above the exemption level always pass and do not consume tokens. The number of dropped lines is
+// reported by a "suppressed N lines" WARN line in the handler format, at most once per summary interval: before
+// the next accepted line, or once the interval elapses if no line is accepted meanwhile, or on Flush.
+type RateLimiter struct {
+	lastSummary     time.Time
+	now             func() time.Time
+	lines           *tokenBucket
+	bytes           *tokenBucket
+	handler         *UnstructuredHandler
+	timer           *time.Timer
+	suppressed      uint64
+	summaryInterval time.Duration
+	exemptLevel     slog.Level
+	mu              sync.Mutex
+}
+
+type tokenBucket struct {
+	last   time.Time
+	tokens float64
+	rate   float64
+	burst  float64
+}
+
+// NewRateLimiter creates a new RateLimiter instance. Without WithLineRate or WithByteRate it accepts every line.
+func NewRateLimiter(opts ...RateLimiterOption) *RateLimiter {
+	//nolint:exhaustruct
+	limiter := &RateLimiter{
+		exemptLevel:     slog.LevelError,
+		summaryInterval: defaultSummaryInterval,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(limiter)
+	}
+	return limiter
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	//nolint:exhaustruct
+	return &tokenBucket{rate: rate, burst: float64(max(burst, 1)), tokens: float64(max(burst, 1))}
+}
+
+// Suppressed returns the number of lines dropped since the last summary.
+func (r *RateLimiter) Suppressed() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.suppressed
+}
+
+// Flush writes the summary of the suppressed lines, if any.
+func (r *RateLimiter) Flush() error {
+	r.mu.Lock()
+	handler, suppressed := r.takeSummary(r.now())
+	r.mu.Unlock()
+	return writeSuppressed(handler, time.Now(), suppressed)
+}
+
+// allow reports whether a line of the given level and size, logged by the given handler, can be written now.
+// When it can, it also returns the number of suppressed lines to report before it, if a summary is due.
+func (r *RateLimiter) allow(handler *UnstructuredHandler, level slog.Level, size int) (bool, uint64) {
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if level < r.exemptLevel {
+		cost := float64(size)
+		if r.lines != nil {
+			r.lines.refill(now)
+		}
+		if r.bytes != nil {
+			r.bytes.refill(now)
+			cost = min(cost, r.bytes.burst)
+		}
+		if (r.lines != nil && r.lines.tokens < 1) || (r.bytes != nil && r.bytes.tokens < cost) {
+			r.suppressed++
+			r.handler = handler
+			if r.timer == nil {
+				r.timer = time.AfterFunc(r.summaryInterval, r.summarize)
+			}
+			return false, 0
+		}
+		if r.lines != nil {
+			r.lines.tokens--
+		}
+		if r.bytes != nil {
+			r.bytes.tokens -= cost
+		}
+	}
+	if r.suppressed == 0 || now.Sub(r.lastSummary) < r.summaryInterval {
+		return true, 0
+	}
+	_, suppressed := r.takeSummary(now)
+	return true, suppressed
+}
+
+// summarize writes the summary of the lines suppressed since no line was accepted, once the summary interval
+// elapsed after the first of them.
+func (r *RateLimiter) summarize() {
+	r.mu.Lock()
+	r.timer = nil
+	if r.suppressed == 0 {
+		r.mu.Unlock()
+		return
+	}
+	if wait := r.summaryInterval - r.now().Sub(r.lastSummary); wait > 0 {
+		r.timer = time.AfterFunc(wait, r.summarize)
+		r.mu.Unlock()
+		return
+	}
+	handler, suppressed := r.takeSummary(r.now())
+	r.mu.Unlock()
+	if err := writeSuppressed(handler, time.Now(), suppressed); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "rateLimiter error: %v\n", err)
+	}
+}
+
+// takeSummary returns the handler of the last suppressed line and the number of suppressed lines, and resets it.
+func (r *RateLimiter) takeSummary(now time.Time) (*UnstructuredHandler, uint64) {
+	suppressed := r.suppressed
+	r.suppressed = 0
+	if suppressed > 0 {
+		r.lastSummary = now
+	}
+	return r.handler, suppressed
+}
+
+func writeSuppressed(handler *UnstructuredHandler, now time.Time, suppressed uint64) error {
+	if suppressed == 0 {
+		return nil
+	}
+	return handler.writeSummary(now, slog.LevelWarn, "suppressed "+strconv.FormatUint(suppressed, decimalBase)+" lines")
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	// A clock going backwards never drains the bucket.
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+}
@@ -0,0 +1,167 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func handleAt(t *testing.T, handler slog.Handler, at time.Time, level slog.Level, msg string) {
+	t.Helper()
+	if err := handler.Handle(context.Background(), slog.NewRecord(at, level, msg, 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// fakeClock is a clock that only moves when set.
+type fakeClock struct {
+	now time.Time
+	mu  sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+}
+
+// handleTicking sets the clock to the given time and handles a record stamped with it.
+func handleTicking(t *testing.T, handler slog.Handler, clock *fakeClock, at time.Time, level slog.Level, msg string) {
+	t.Helper()
+	clock.Set(at)
+	handleAt(t, handler, at, level, msg)
+}
+
+func TestRateLimiter_LinesWithBurst(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	limiter := uslogs.NewRateLimiter(uslogs.WithLineRate(1, 2), uslogs.WithRateClock(clock.Now))
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithRateLimiter(limiter))
+
+	for range 5 {
+		handleAt(t, handler, start, slog.LevelInfo, "storm")
+	}
+	if limiter.Suppressed() != 3 {
+		t.Fatalf("got %d suppressed lines, expected 3", limiter.Suppressed())
+	}
+	handleAt(t, handler, start, slog.LevelError, "exempt")
+	handleTicking(t, handler, clock, start.Add(time.Second), slog.LevelInfo, "recovered")
+
+	expected := "INFO storm\nINFO storm\nWARN suppressed 3 lines\nERROR exempt\nINFO recovered\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestRateLimiter_Bytes(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	limiter := uslogs.NewRateLimiter(uslogs.WithByteRate(10, 20), uslogs.WithRateClock(clock.Now))
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithRateLimiter(limiter))
+
+	handleAt(t, handler, start, slog.LevelInfo, "0123456789")
+	handleAt(t, handler, start, slog.LevelInfo, "0123456789")
+	handleTicking(t, handler, clock, start.Add(2*time.Second), slog.LevelInfo, "0123456789")
+
+	expected := "INFO 0123456789\nWARN suppressed 1 lines\nINFO 0123456789\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestRateLimiter_SummaryInterval(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	limiter := uslogs.NewRateLimiter(uslogs.WithLineRate(1, 1), uslogs.WithSummaryInterval(time.Minute),
+		uslogs.WithRateClock(clock.Now))
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithRateLimiter(limiter),
+		uslogs.WithTimestamp())
+
+	for second := range 4 {
+		handleTicking(t, handler, clock, start.Add(time.Duration(second)*time.Second), slog.LevelInfo, "tick")
+		handleTicking(t, handler, clock, start.Add(time.Duration(second)*time.Second), slog.LevelInfo, "tick")
+	}
+
+	if count := strings.Count(out.String(), "suppressed"); count != 1 {
+		t.Fatalf("got %d summaries in %q, expected 1", count, out.String())
+	}
+	if !strings.Contains(out.String(), "2025-01-01T00:00:01Z WARN suppressed 1 lines\n") {
+		t.Fatalf("got %q, expected a summary in the handler format", out.String())
+	}
+	if limiter.Suppressed() != 3 {
+		t.Fatalf("got %d suppressed lines, expected 3 pending", limiter.Suppressed())
+	}
+}
+
+func TestRateLimiter_OutOfOrderTimestampsDoNotDrainTokens(t *testing.T) {
+	var out bytes.Buffer
+	limiter := uslogs.NewRateLimiter(uslogs.WithLineRate(1, 5))
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithRateLimiter(limiter))
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for idx := range 5 {
+		handleAt(t, handler, start.Add(time.Duration(idx%2)*time.Hour), slog.LevelInfo, "tick")
+	}
+
+	if limiter.Suppressed() != 0 {
+		t.Fatalf("got %d suppressed lines, expected none within the burst", limiter.Suppressed())
+	}
+}
+
+func TestRateLimiter_SummaryAfterQuietPeriod(t *testing.T) {
+	//nolint:exhaustruct
+	out := &lockedBuffer{}
+	limiter := uslogs.NewRateLimiter(uslogs.WithLineRate(1, 1), uslogs.WithSummaryInterval(20*time.Millisecond))
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(out), uslogs.WithRateLimiter(limiter))
+
+	for range 3 {
+		handleAt(t, handler, time.Now(), slog.LevelInfo, "storm")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if lines := out.Lines(); len(lines) == 2 {
+			if lines[1] != "WARN suppressed 2 lines" {
+				t.Fatalf("got %q, expected the summary", lines[1])
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("got %q, expected a summary once the interval elapsed", out.Lines())
+}
+
+func TestRateLimiter_Flush(t *testing.T) {
+	var out bytes.Buffer
+	limiter := uslogs.NewRateLimiter(uslogs.WithLineRate(1, 1), uslogs.WithSummaryInterval(time.Hour))
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithRateLimiter(limiter))
+
+	handleAt(t, handler, time.Now(), slog.LevelInfo, "storm")
+	handleAt(t, handler, time.Now(), slog.LevelInfo, "storm")
+	if err := limiter.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := limiter.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "INFO storm\nWARN suppressed 1 lines\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
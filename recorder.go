@@ -0,0 +1,181 @@
+package uslogs
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const defaultRecorderCapacity = 1000
+
+// FlightRecorderOption represents a function that configures a FlightRecorder.
+type FlightRecorderOption = func(r *FlightRecorder)
+
+// WithRecorderCapacity sets the number of lines kept in memory. Defaults to 1000.
+func WithRecorderCapacity(lines int) FlightRecorderOption {
+	return func(recorder *FlightRecorder) {
+		recorder.slots = make([]recordedLine, max(lines, 1))
+	}
+}
+
+// WithRecorderWindow only dumps lines recorded within the given duration before the dump. Defaults to dumping
+// every line in memory.
+func WithRecorderWindow(window time.Duration) FlightRecorderOption {
+	return func(recorder *FlightRecorder) {
+		recorder.window = window
+	}
+}
+
+// WithRecorderLevel sets the lowest level kept in memory. Defaults to slog.LevelDebug.
+func WithRecorderLevel(level slog.Level) FlightRecorderOption {
+	return func(recorder *FlightRecorder) {
+		recorder.recordLevel = level
+	}
+}
+
+// WithRecorderPassLevel sets the lowest level written straight to the output, without waiting for a dump.
+// Defaults to slog.LevelInfo.
+func WithRecorderPassLevel(level slog.Level) FlightRecorderOption {
+	return func(recorder *FlightRecorder) {
+		recorder.passLevel = level
+	}
+}
+
+// WithRecorderTrigger sets the lowest level that dumps the recorded lines. Defaults to slog.LevelError.
+func WithRecorderTrigger(level slog.Level) FlightRecorderOption {
+	return func(recorder *FlightRecorder) {
+		recorder.triggerLevel = level
+	}
+}
+
+// FlightRecorder keeps the last lines in a fixed-size in-memory ring and only writes them to the output when
+// something goes wrong: a line at the trigger level, a panic caught by DumpOnPanic, or an explicit Dump.
+//
+// Lines at or above the pass level are written to the output right away instead of being kept, so they never
+// evict the lines below it that the ring exists for. Ring slots keep their buffers between lines, so recording
+// a line is a copy under a mutex.
+type FlightRecorder struct {
+	out          io.Writer
+	slots        []recordedLine
+	next         int
+	window       time.Duration
+	recordLevel  slog.Level
+	passLevel    slog.Level
+	triggerLevel slog.Level
+	mu           sync.Mutex
+}
+
+type recordedLine struct {
+	time time.Time
+	buf  *[]byte
+	used bool
+}
+
+// NewFlightRecorder creates a new FlightRecorder instance dumping to the given writer.
+func NewFlightRecorder(out io.Writer, opts ...FlightRecorderOption) *FlightRecorder {
+	//nolint:exhaustruct
+	recorder := &FlightRecorder{
+		out:          out,
+		slots:        make([]recordedLine, defaultRecorderCapacity),
+		recordLevel:  slog.LevelDebug,
+		passLevel:    slog.LevelInfo,
+		triggerLevel: slog.LevelError,
+	}
+	for _, opt := range opts {
+		opt(recorder)
+	}
+	return recorder
+}
+
+// MinLevel returns the lowest level kept in memory.
+func (r *FlightRecorder) MinLevel() slog.Level {
+	return r.recordLevel
+}
+
+// Write records the given line as if it had been logged at slog.LevelInfo.
+func (r *FlightRecorder) Write(input []byte) (int, error) {
+	return r.WriteLevel(slog.LevelInfo, input)
+}
+
+// WriteLevel writes the given line to the output if it reaches the pass level or records it otherwise. A line
+// reaching the trigger level dumps the recorded lines first and is always written after them.
+func (r *FlightRecorder) WriteLevel(level slog.Level, input []byte) (int, error) {
+	if level < r.recordLevel {
+		return len(input), nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	if level >= r.triggerLevel {
+		err = r.dump()
+	}
+	if level >= r.passLevel || level >= r.triggerLevel {
+		if _, writeErr := r.out.Write(input); writeErr != nil {
+			return 0, errors.Join(err, writeErr)
+		}
+	}
+	if level < r.passLevel && level < r.triggerLevel {
+		r.record(input)
+	}
+	return len(input), err
+}
+
+// Dump writes the recorded lines to the output, oldest first, and clears the ring.
+func (r *FlightRecorder) Dump() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dump()
+}
+
+// DumpOnPanic dumps the recorded lines if the goroutine is panicking and then resumes the panic. It must be
+// deferred directly:
+//
+//	defer recorder.DumpOnPanic()
+func (r *FlightRecorder) DumpOnPanic() {
+	if recovered := recover(); recovered != nil {
+		_ = r.Dump()
+		panic(recovered)
+	}
+}
+
+func (r *FlightRecorder) record(input []byte) {
+	slot := &r.slots[r.next]
+	if slot.buf == nil || cap(*slot.buf) < len(input) {
+		if slot.buf != nil {
+			logutils.PutPool(logutils.BytesPools.GetPool(cap(*slot.buf)), slot.buf)
+		}
+		slot.buf = logutils.BytesPools.GetPool(len(input)).Get().(*[]byte) //nolint:forcetypeassert
+	}
+	*slot.buf = append((*slot.buf)[:0], input...)
+	slot.used = true
+	if r.window > 0 {
+		slot.time = time.Now()
+	}
+	r.next = (r.next + 1) % len(r.slots)
+}
+
+func (r *FlightRecorder) dump() error {
+	var cutoff time.Time
+	if r.window > 0 {
+		cutoff = time.Now().Add(-r.window)
+	}
+	var err error
+	for idx := range r.slots {
+		slot := &r.slots[(r.next+idx)%len(r.slots)]
+		if !slot.used {
+			continue
+		}
+		slot.used = false
+		if r.window > 0 && slot.time.Before(cutoff) {
+			continue
+		}
+		if _, writeErr := r.out.Write(*slot.buf); writeErr != nil {
+			err = errors.Join(err, writeErr)
+		}
+	}
+	return err
+}
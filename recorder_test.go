@@ -0,0 +1,115 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestFlightRecorder_DumpsOnError(t *testing.T) {
+	var out bytes.Buffer
+	recorder := uslogs.NewFlightRecorder(&out)
+	logger := slog.New(uslogs.NewUnstructuredHandler(uslogs.WithFlightRecorder(recorder)))
+
+	logger.Debug("connecting", "attempt", 1)
+	logger.Info("started")
+	logger.Debug("retrying", "attempt", 2)
+
+	if out.String() != "INFO started\n" {
+		t.Fatalf("got %q before the error, expected only the info line", out.String())
+	}
+
+	logger.Error("failed")
+
+	expected := "INFO started\nDEBUG connecting attempt=1\nDEBUG retrying attempt=2\nERROR failed\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+
+	out.Reset()
+	logger.Error("again")
+	if out.String() != "ERROR again\n" {
+		t.Fatalf("got %q, expected the ring to be cleared after a dump", out.String())
+	}
+}
+
+func TestFlightRecorder_KeepsLastLines(t *testing.T) {
+	var out bytes.Buffer
+	recorder := uslogs.NewFlightRecorder(&out, uslogs.WithRecorderCapacity(2))
+
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		_, _ = recorder.WriteLevel(slog.LevelDebug, []byte(line))
+	}
+	if err := recorder.Dump(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out.String() != "two\nthree\n" {
+		t.Fatalf("got %q, expected the last two lines", out.String())
+	}
+}
+
+func TestFlightRecorder_PassLinesDoNotEvictRecordedLines(t *testing.T) {
+	var out bytes.Buffer
+	recorder := uslogs.NewFlightRecorder(&out, uslogs.WithRecorderCapacity(3))
+
+	_, _ = recorder.WriteLevel(slog.LevelDebug, []byte("debug\n"))
+	for range 3 {
+		_, _ = recorder.WriteLevel(slog.LevelInfo, []byte("info\n"))
+	}
+	_, _ = recorder.WriteLevel(slog.LevelError, []byte("error\n"))
+
+	expected := "info\ninfo\ninfo\ndebug\nerror\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestFlightRecorder_TriggerBelowPassLevel(t *testing.T) {
+	var out bytes.Buffer
+	recorder := uslogs.NewFlightRecorder(&out, uslogs.WithRecorderPassLevel(slog.LevelError+4))
+
+	_, _ = recorder.WriteLevel(slog.LevelDebug, []byte("debug\n"))
+	_, _ = recorder.WriteLevel(slog.LevelError, []byte("error\n"))
+
+	if out.String() != "debug\nerror\n" {
+		t.Fatalf("got %q, expected the dump followed by the triggering line", out.String())
+	}
+}
+
+func TestFlightRecorder_Window(t *testing.T) {
+	var out bytes.Buffer
+	recorder := uslogs.NewFlightRecorder(&out, uslogs.WithRecorderWindow(20*time.Millisecond))
+
+	_, _ = recorder.WriteLevel(slog.LevelDebug, []byte("old\n"))
+	time.Sleep(40 * time.Millisecond)
+	_, _ = recorder.WriteLevel(slog.LevelDebug, []byte("recent\n"))
+	_ = recorder.Dump()
+
+	if out.String() != "recent\n" {
+		t.Fatalf("got %q, expected only the recent line", out.String())
+	}
+}
+
+func TestFlightRecorder_DumpOnPanic(t *testing.T) {
+	var out bytes.Buffer
+	recorder := uslogs.NewFlightRecorder(&out)
+	_, _ = recorder.WriteLevel(slog.LevelDebug, []byte("before panic\n"))
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Fatalf("got %v, expected the panic to be resumed", recovered)
+			}
+		}()
+		defer recorder.DumpOnPanic()
+		panic("boom")
+	}()
+
+	if out.String() != "before panic\n" {
+		t.Fatalf("got %q, expected the recorded line", out.String())
+	}
+}
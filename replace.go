@@ -0,0 +1,183 @@
+package uslogs
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+const maxPooledPinnedBytes = 64 * 1024
+
+// ReplaceAttrFunc rewrites an attribute before it is written, as slog.HandlerOptions.ReplaceAttr does. groups
+// holds the path of the groups the attribute belongs to, and must not be retained. The attribute is dropped when
+// the returned key is empty. It is never called for group attributes, only for their members.
+type ReplaceAttrFunc = func(groups []string, attr slog.Attr) slog.Attr
+
+// attrRule is the precomputed declarative rule of an attribute key.
+type attrRule struct {
+	rename string
+	drop   bool
+}
+
+// compileAttrRules turns the renamed and dropped keys into the rules looked up for every attribute, and renames
+// the keys of the time, level, message and source.
+func (l *UnstructuredHandler) compileAttrRules() {
+	if len(l.renamedKeys) == 0 && len(l.droppedKeys) == 0 {
+		l.attrRules = nil
+	} else {
+		l.attrRules = make(map[string]attrRule, len(l.renamedKeys)+len(l.droppedKeys))
+		for key, rename := range l.renamedKeys {
+			l.attrRules[key] = attrRule{rename: rename, drop: false}
+		}
+		for _, key := range l.droppedKeys {
+			l.attrRules[key] = attrRule{rename: "", drop: true}
+		}
+	}
+	for _, key := range []*string{&l.timeKey, &l.levelKey, &l.messageKey, &l.sourceKey} {
+		if rename, ok := l.renamedKeys[*key]; ok && *key != "" {
+			*key = rename
+		}
+	}
+	l.rewriting = l.attrRules != nil || l.replaceAttr != nil
+	l.pinnedAttrs = make([][]byte, len(l.pinnedKeys))
+}
+
+// rewrite applies the declarative rules and the ReplaceAttrFunc to the given attribute, if any, and reports
+// whether it is kept.
+func (l *UnstructuredHandler) rewrite(groups []string, attr slog.Attr) (slog.Attr, bool) {
+	if !l.rewriting {
+		return attr, true
+	}
+	return l.rewriteAttr(groups, attr)
+}
+
+// rewriteAttr applies the declarative rules and the ReplaceAttrFunc to the given attribute, whose group path is
+// groups, and reports whether it is kept. Groups left empty are dropped. Masked attributes are masked before they
+// are renamed, so they stay masked whatever their new key.
+func (l *UnstructuredHandler) rewriteAttr(groups []string, attr slog.Attr) (slog.Attr, bool) {
+	attr.Value = attr.Value.Resolve()
+	masked := attr.Value.Kind() != slog.KindGroup && slices.Contains(l.maskedAttrs, attr.Key)
+	if rule, ok := l.attrRule(groups, attr.Key); ok {
+		if rule.drop {
+			return attr, false
+		}
+		attr.Key = rule.rename
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		nested := groups
+		if attr.Key != "" {
+			nested = append(slices.Clip(groups), attr.Key)
+		}
+		members := attr.Value.Group()
+		kept := make([]slog.Attr, 0, len(members))
+		for _, member := range members {
+			if member, ok := l.rewriteAttr(nested, member); ok {
+				kept = append(kept, member)
+			}
+		}
+		attr.Value = slog.GroupValue(kept...)
+		return attr, len(kept) > 0
+	}
+	if masked {
+		attr.Value = slog.StringValue(maskedFieldValue)
+	}
+	if l.replaceAttr != nil {
+		attr = l.replaceAttr(groups, attr)
+		attr.Value = attr.Value.Resolve()
+	}
+	return attr, attr.Key != ""
+}
+
+// attrRule looks up the rule of the given key qualified by the group path.
+func (l *UnstructuredHandler) attrRule(groups []string, key string) (attrRule, bool) {
+	if l.attrRules == nil {
+		return attrRule{}, false //nolint:exhaustruct
+	}
+	if len(groups) == 0 {
+		rule, ok := l.attrRules[key]
+		return rule, ok
+	}
+	var scratch [128]byte
+	qualified := scratch[:0]
+	for _, group := range groups {
+		qualified = append(qualified, group...)
+		qualified = append(qualified, l.groupSeparator)
+	}
+	qualified = append(qualified, key...)
+	rule, ok := l.attrRules[string(qualified)]
+	return rule, ok
+}
+
+// pinnedSpan is the position in pinnedAttrs.bytes of a formatted attribute whose key is pinned.
+type pinnedSpan struct {
+	pin   int
+	start int
+	end   int
+}
+
+// pinnedAttrs holds the formatted attributes whose keys are pinned apart from the others, until they are
+// written first in the order of the pinned keys.
+type pinnedAttrs struct {
+	bytes []byte
+	spans []pinnedSpan
+}
+
+//nolint:gochecknoglobals
+var pinnedAttrsPool = sync.Pool{
+	New: func() any {
+		return &pinnedAttrs{bytes: make([]byte, 0, 1024), spans: make([]pinnedSpan, 0, 8)} //nolint:mnd
+	},
+}
+
+func newPinnedAttrs() *pinnedAttrs {
+	pins := pinnedAttrsPool.Get().(*pinnedAttrs) //nolint:forcetypeassert
+	pins.bytes = pins.bytes[:0]
+	pins.spans = pins.spans[:0]
+	return pins
+}
+
+func (p *pinnedAttrs) release() {
+	// As with byte pools, huge buffers are not kept.
+	if cap(p.bytes) > maxPooledPinnedBytes {
+		return
+	}
+	pinnedAttrsPool.Put(p)
+}
+
+// appendRewrittenAttr rewrites the given attribute and appends it, unless it is dropped.
+func (l *UnstructuredHandler) appendRewrittenAttr(
+	dst []byte, pins *pinnedAttrs, group []byte, groups []string, attr slog.Attr,
+) []byte {
+	attr, ok := l.rewrite(groups, attr)
+	if !ok {
+		return dst
+	}
+	return l.appendPinnableAttr(dst, pins, group, attr)
+}
+
+// appendPinnableAttr appends the given attribute unless, outside groups, its key is pinned, in which case it is
+// held in pins.
+func (l *UnstructuredHandler) appendPinnableAttr(dst []byte, pins *pinnedAttrs, group []byte, attr slog.Attr) []byte {
+	if pins != nil && len(group) == 0 {
+		if pin := slices.Index(l.pinnedKeys, attr.Key); pin >= 0 {
+			start := len(pins.bytes)
+			pins.bytes = l.appendGroupedAttr(pins.bytes, nil, attr)
+			pins.spans = append(pins.spans, pinnedSpan{pin: pin, start: start, end: len(pins.bytes)})
+			return dst
+		}
+	}
+	return l.appendGroupedAttr(dst, group, attr)
+}
+
+// appendPinned appends the pinned attributes of the handler and of the record, in the order of the pinned keys.
+func (l *UnstructuredHandler) appendPinned(dst []byte, pins *pinnedAttrs) []byte {
+	for pin := range l.pinnedKeys {
+		dst = append(dst, l.pinnedAttrs[pin]...)
+		for _, span := range pins.spans {
+			if span.pin == pin {
+				dst = append(dst, pins.bytes[span.start:span.end]...)
+			}
+		}
+	}
+	return dst
+}
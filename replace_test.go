@@ -0,0 +1,121 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestUnstructuredHandler_ReplaceAttrMatchesSlog(t *testing.T) {
+	replace := func(groups []string, attr slog.Attr) slog.Attr {
+		switch {
+		case len(groups) == 0 && attr.Key == slog.TimeKey:
+			return slog.Attr{}
+		case attr.Key == "secret":
+			return slog.Attr{}
+		case strings.Join(groups, ".") == "http.req" && attr.Key == "path":
+			return slog.String("route", strings.ToUpper(attr.Value.String()))
+		}
+		return attr
+	}
+	log := func(logger *slog.Logger) {
+		logger.With("service", "api", "secret", "x").WithGroup("http").With("method", "GET").WithGroup("req").
+			Info("served", "path", "/users", "secret", "y", slog.Group("only", "secret", "z"))
+	}
+
+	var expected bytes.Buffer
+	log(slog.New(slog.NewJSONHandler(&expected, &slog.HandlerOptions{ReplaceAttr: replace}))) //nolint:exhaustruct
+	var got bytes.Buffer
+	log(slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&got),
+		uslogs.WithFormat(uslogs.FormatJSON),
+		uslogs.WithReplaceAttr(replace),
+	)))
+
+	gotObjects, expectedObjects := decodeJSONLines(t, got.String()), decodeJSONLines(t, expected.String())
+	if !reflect.DeepEqual(gotObjects, expectedObjects) {
+		t.Fatalf("got %s, expected %s", got.String(), expected.String())
+	}
+}
+
+func TestUnstructuredHandler_RenamedAndDroppedKeys(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatLogfmt),
+		uslogs.WithRenamedKeys(map[string]string{"msg": "message", "user": "usr", "http.status": "code"}),
+		uslogs.WithDroppedKeys("password", "http.body"),
+	))
+
+	logger.With("user", "alice", "password", "x").Info("served",
+		slog.Group("http", slog.Int("status", 200), slog.String("body", "{}")),
+		slog.Group("db", slog.String("body", "y")))
+
+	expected := "level=INFO message=served usr=alice http.code=200 db.body=y\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_PinnedKeys(t *testing.T) {
+	for _, format := range []uslogs.Format{uslogs.FormatText, uslogs.FormatJSON} {
+		var out bytes.Buffer
+		logger := slog.New(uslogs.NewUnstructuredHandler(
+			uslogs.WithWriter(&out),
+			uslogs.WithFormat(format),
+			uslogs.WithContextExtractors(uslogs.AppendContextAttrs),
+			uslogs.WithRenamedKeys(map[string]string{"uid": "user"}),
+			uslogs.WithPinnedKeys("request_id", "user"),
+		))
+		ctx := uslogs.ContextWithAttrs(context.Background(), slog.String("tenant", "acme"), slog.String("request_id", "r1"))
+
+		logger.With("service", "api", "uid", "alice").InfoContext(ctx, "served", "status", 200)
+		logger.WithGroup("http").InfoContext(ctx, "grouped", "user", "bob")
+
+		var expected string
+		if format == uslogs.FormatJSON {
+			expected = `{"level":"INFO","msg":"served","request_id":"r1","user":"alice","tenant":"acme",` +
+				`"service":"api","status":200}` + "\n" +
+				`{"level":"INFO","msg":"grouped","request_id":"r1","tenant":"acme","http":{"user":"bob"}}` + "\n"
+		} else {
+			expected = "INFO served request_id=r1 user=alice service=api tenant=acme status=200\n" +
+				"INFO grouped request_id=r1 tenant=acme http.user=bob\n"
+		}
+		if out.String() != expected {
+			t.Errorf("format %d: got %q, expected %q", format, out.String(), expected)
+		}
+	}
+}
+
+func TestUnstructuredHandler_RewrittenKeysStayMasked(t *testing.T) {
+	var out bytes.Buffer
+	var seen []string
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithMaskedAttributes("password", "token"),
+		uslogs.WithRenamedKeys(map[string]string{"password": "pwd"}),
+		uslogs.WithReplaceAttr(func(_ []string, attr slog.Attr) slog.Attr {
+			seen = append(seen, attr.Value.String())
+			if attr.Key == "token" {
+				attr.Key = "tok"
+			}
+			return attr
+		}),
+	))
+
+	logger.With("password", "hunter2").Info("login", slog.Group("auth", slog.String("token", "s3cr3t")))
+
+	expected := "INFO login pwd=<MASKED> auth=[tok=<MASKED>]\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+	if slices.Contains(seen, "hunter2") || slices.Contains(seen, "s3cr3t") {
+		t.Fatalf("ReplaceAttr saw masked values: %q", seen)
+	}
+}
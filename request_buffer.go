@@ -0,0 +1,231 @@
+package uslogs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+// RequestBufferOption represents a function that configures a RequestBuffer.
+type RequestBufferOption = func(b *RequestBuffer)
+
+// WithLatencyThreshold writes the held lines when the request takes longer than the given duration, even if it
+// succeeded. Defaults to no threshold.
+func WithLatencyThreshold(threshold time.Duration) RequestBufferOption {
+	return func(buffer *RequestBuffer) {
+		buffer.threshold = threshold
+	}
+}
+
+// WithFailureLevel sets the lowest level of a line that marks the request as failed. Defaults to slog.LevelError.
+func WithFailureLevel(level slog.Level) RequestBufferOption {
+	return func(buffer *RequestBuffer) {
+		buffer.failureLevel = level
+	}
+}
+
+// WithFailureStatus sets the lowest HTTP status code that marks the request as failed in
+// RequestBufferMiddleware. Defaults to 500.
+func WithFailureStatus(status int) RequestBufferOption {
+	return func(buffer *RequestBuffer) {
+		buffer.failureStatus = status
+	}
+}
+
+// RequestBuffer holds the lines logged with its context until the request finishes, so lines below the handler
+// level are only written for requests that failed or were too slow.
+//
+// Lines the handler would write anyway are held too, so the output keeps the order of the request. Once the
+// request fails, the held lines are written and the following ones go straight to the handler writer.
+type RequestBuffer struct {
+	start         time.Time
+	lines         []heldLine
+	threshold     time.Duration
+	failureStatus int
+	failureLevel  slog.Level
+	mu            sync.Mutex
+	failed        bool
+	finished      bool
+}
+
+type heldLine struct {
+	handler      *UnstructuredHandler
+	buf          *[]byte
+	key          string
+	contextAttrs []slog.Attr
+	record       slog.Record
+}
+
+type requestBufferKey struct{}
+
+// NewRequestBuffer creates a new RequestBuffer and returns a context carrying it. Handlers configured with
+// WithRequestBuffering hold the lines logged with that context until Finish is called.
+func NewRequestBuffer(ctx context.Context, opts ...RequestBufferOption) (context.Context, *RequestBuffer) {
+	//nolint:exhaustruct
+	buffer := &RequestBuffer{
+		start:         time.Now(),
+		failureLevel:  slog.LevelError,
+		failureStatus: http.StatusInternalServerError,
+	}
+	for _, opt := range opts {
+		opt(buffer)
+	}
+	return context.WithValue(ctx, requestBufferKey{}, buffer), buffer
+}
+
+// RequestBufferFromContext returns the RequestBuffer carried by the given context, or nil if there is none.
+func RequestBufferFromContext(ctx context.Context) *RequestBuffer {
+	buffer, _ := ctx.Value(requestBufferKey{}).(*RequestBuffer)
+	return buffer
+}
+
+// Fail marks the request as failed and writes the held lines.
+func (b *RequestBuffer) Fail() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed = true
+	return b.flush()
+}
+
+// Finish writes the held lines if the request failed or exceeded the latency threshold. Otherwise, it only
+// writes the lines that reached the level of their handler and drops the rest. Lines logged afterwards are
+// handled as if there was no buffer.
+func (b *RequestBuffer) Finish() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finished {
+		return nil
+	}
+	b.finished = true
+	if b.threshold > 0 && time.Since(b.start) > b.threshold {
+		b.failed = true
+	}
+	return b.flush()
+}
+
+// hold keeps a copy of the given line until the request finishes. It returns false when the line must be
+// handled right away instead, because the request already failed or finished.
+func (b *RequestBuffer) hold(
+	handler *UnstructuredHandler, key string, record slog.Record, contextAttrs []slog.Attr, line []byte,
+) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finished || b.failed {
+		return false, nil
+	}
+	if record.Level >= b.failureLevel {
+		b.failed = true
+		return false, b.flush()
+	}
+	buf := logutils.BytesPools.GetPool(len(line)).Get().(*[]byte) //nolint:forcetypeassert
+	*buf = append((*buf)[:0], line...)
+	b.lines = append(b.lines, heldLine{
+		handler:      handler,
+		buf:          buf,
+		key:          key,
+		contextAttrs: slices.Clone(contextAttrs),
+		record:       record.Clone(),
+	})
+	return true, nil
+}
+
+// admits reports whether a line logged after the request finished must be written.
+func (b *RequestBuffer) admits(handler *UnstructuredHandler, level slog.Level) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failed || level >= handler.level
+}
+
+func (b *RequestBuffer) flush() error {
+	var err error
+	for idx := range b.lines {
+		line := &b.lines[idx]
+		if b.failed || line.record.Level >= line.handler.level {
+			if writeErr := line.handler.write(line.key, line.record, line.contextAttrs, *line.buf); writeErr != nil {
+				err = errors.Join(err, writeErr)
+			}
+		}
+		logutils.PutPool(logutils.BytesPools.GetPool(cap(*line.buf)), line.buf)
+		*line = heldLine{} //nolint:exhaustruct
+	}
+	b.lines = b.lines[:0]
+	return err
+}
+
+// RequestBufferMiddleware creates a RequestBuffer for every request and finishes it once the next handler
+// returns. The request is marked as failed when the response status reaches the failure status or the next
+// handler panics.
+func RequestBufferMiddleware(next http.Handler, opts ...RequestBufferOption) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx, buffer := NewRequestBuffer(request.Context(), opts...)
+		//nolint:exhaustruct
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		defer func() {
+			var err error
+			recovered := recover()
+			if recovered != nil || recorder.status >= buffer.failureStatus {
+				err = buffer.Fail()
+			}
+			if err = errors.Join(err, buffer.Finish()); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "requestBuffer error: %v\n", err)
+			}
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+	})
+}
+
+// statusRecorder keeps the status code written by the next handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(input []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(input) //nolint:wrapcheck
+}
+
+// Unwrap returns the original ResponseWriter, so http.ResponseController can reach its optional methods.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush sends any buffered data to the client, when the original ResponseWriter supports it.
+func (r *statusRecorder) Flush() {
+	r.wroteHeader = true
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack lets the next handler take over the connection, when the original ResponseWriter supports it.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(r.ResponseWriter).Hijack() //nolint:wrapcheck
+}
+
+// ReadFrom copies the body from the given reader, keeping the io.ReaderFrom of the original ResponseWriter.
+func (r *statusRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.wroteHeader = true
+	return io.Copy(r.ResponseWriter, src) //nolint:wrapcheck
+}
@@ -0,0 +1,161 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func newBufferedLogger(out *bytes.Buffer) *slog.Logger {
+	return slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(out),
+		uslogs.WithRequestBuffering(slog.LevelDebug),
+	))
+}
+
+func TestRequestBuffer_DropsDebugOnSuccess(t *testing.T) {
+	var out bytes.Buffer
+	logger := newBufferedLogger(&out)
+	ctx, buffer := uslogs.NewRequestBuffer(context.Background())
+
+	logger.DebugContext(ctx, "loading", "id", 1)
+	logger.InfoContext(ctx, "loaded")
+	logger.Info("outside")
+
+	if out.String() != "INFO outside\n" {
+		t.Fatalf("got %q, expected request lines to be held", out.String())
+	}
+	if err := buffer.Finish(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "INFO outside\nINFO loaded\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestRequestBuffer_WritesEverythingOnError(t *testing.T) {
+	var out bytes.Buffer
+	logger := newBufferedLogger(&out)
+	ctx, buffer := uslogs.NewRequestBuffer(context.Background())
+
+	logger.DebugContext(ctx, "loading", "id", 1)
+	logger.InfoContext(ctx, "loaded")
+	logger.ErrorContext(ctx, "failed")
+	logger.DebugContext(ctx, "cleanup")
+	_ = buffer.Finish()
+
+	expected := "DEBUG loading id=1\nINFO loaded\nERROR failed\nDEBUG cleanup\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestRequestBuffer_LatencyThreshold(t *testing.T) {
+	var out bytes.Buffer
+	logger := newBufferedLogger(&out)
+	ctx, buffer := uslogs.NewRequestBuffer(context.Background(), uslogs.WithLatencyThreshold(10*time.Millisecond))
+
+	logger.DebugContext(ctx, "slow query")
+	time.Sleep(20 * time.Millisecond)
+	_ = buffer.Finish()
+
+	if out.String() != "DEBUG slow query\n" {
+		t.Fatalf("got %q, expected the debug line of a slow request", out.String())
+	}
+}
+
+func TestRequestBufferMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger := newBufferedLogger(&out)
+	handler := uslogs.RequestBufferMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.DebugContext(r.Context(), "handling", "path", r.URL.Path)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if out.Len() != 0 {
+		t.Fatalf("got %q, expected nothing for a successful request", out.String())
+	}
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	if response.Code != http.StatusBadGateway {
+		t.Fatalf("got status %d, expected %d", response.Code, http.StatusBadGateway)
+	}
+	if out.String() != "DEBUG handling path=/fail\n" {
+		t.Fatalf("got %q, expected the debug line of the failed request", out.String())
+	}
+}
+
+func TestRequestBufferMiddleware_Panic(t *testing.T) {
+	var out bytes.Buffer
+	logger := newBufferedLogger(&out)
+	handler := uslogs.RequestBufferMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		logger.DebugContext(r.Context(), "before panic")
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Fatalf("got %v, expected the panic to be resumed", recovered)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if out.String() != "DEBUG before panic\n" {
+		t.Fatalf("got %q, expected the debug line of the panicking request", out.String())
+	}
+}
+
+func TestRequestBufferMiddleware_KeepsOptionalInterfaces(t *testing.T) {
+	handler := uslogs.RequestBufferMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if _, ok := w.(io.ReaderFrom); !ok {
+			t.Error("expected the response writer to implement io.ReaderFrom")
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected the response writer to implement http.Flusher")
+		}
+		_, _ = w.Write([]byte("partial\n"))
+		flusher.Flush()
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("expected the response writer to implement http.Hijacker")
+		}
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		_, _ = buf.WriteString("hijacked\n")
+		_ = buf.Flush()
+		_ = conn.Close()
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	//nolint:noctx
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+	body, _ := io.ReadAll(response.Body)
+	if !strings.HasPrefix(string(body), "partial\n") {
+		t.Fatalf("got %q, expected the flushed body", body)
+	}
+}
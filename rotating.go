@@ -0,0 +1,374 @@
+package uslogs
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat  = "2006-01-02T15-04-05.000"
+	compressedSuffix  = ".gz"
+	defaultFileMode   = 0o644
+	defaultDirMode    = 0o755
+	compressedPartial = ".tmp"
+)
+
+// RotatingFileOption represents a function that configures a RotatingFileWriter.
+type RotatingFileOption = func(w *RotatingFileWriter)
+
+// WithMaxSize rotates the file before a write would make it exceed the given size in bytes.
+func WithMaxSize(size int64) RotatingFileOption {
+	return func(rotatingWriter *RotatingFileWriter) {
+		rotatingWriter.maxSize = size
+	}
+}
+
+// WithRotationInterval rotates the file every time the given interval elapses, aligned to the wall clock.
+func WithRotationInterval(interval time.Duration) RotatingFileOption {
+	return func(rotatingWriter *RotatingFileWriter) {
+		rotatingWriter.interval = interval
+	}
+}
+
+// WithMaxBackups sets the maximum number of rotated files to keep. Defaults to keeping all of them.
+func WithMaxBackups(backups int) RotatingFileOption {
+	return func(rotatingWriter *RotatingFileWriter) {
+		rotatingWriter.maxBackups = backups
+	}
+}
+
+// WithMaxAge removes rotated files older than the given age. Defaults to keeping all of them.
+func WithMaxAge(age time.Duration) RotatingFileOption {
+	return func(rotatingWriter *RotatingFileWriter) {
+		rotatingWriter.maxAge = age
+	}
+}
+
+// WithCompression gzips rotated files in the background.
+func WithCompression() RotatingFileOption {
+	return func(rotatingWriter *RotatingFileWriter) {
+		rotatingWriter.compress = true
+	}
+}
+
+// WithReopenOnSIGHUP reopens the file whenever the process receives SIGHUP, so external tools like
+// logrotate can move the file away and signal the process. It has no effect on platforms without SIGHUP.
+func WithReopenOnSIGHUP() RotatingFileOption {
+	return func(rotatingWriter *RotatingFileWriter) {
+		rotatingWriter.reopenOnSignal = true
+	}
+}
+
+// RotatingFileWriter is a writer that writes logs to a file and rotates it by size and/or time.
+//
+// Rotated files are renamed next to the original with a timestamp between the name and the extension,
+// e.g. app-2026-10-16T12-00-00.000.log. It is safe for concurrent use and can be wrapped by an AsyncWriter.
+type RotatingFileWriter struct {
+	file           *os.File
+	signals        chan os.Signal
+	path           string
+	nextRotation   time.Time
+	maxSize        int64
+	size           int64
+	interval       time.Duration
+	maxAge         time.Duration
+	maxBackups     int
+	mu             sync.Mutex
+	millMu         sync.Mutex
+	millWG         sync.WaitGroup
+	compress       bool
+	reopenOnSignal bool
+	closed         bool
+}
+
+// NewRotatingFileWriter creates a new RotatingFileWriter instance writing to the given path. The file is
+// created if it does not exist and appended to otherwise.
+func NewRotatingFileWriter(path string, opts ...RotatingFileOption) (*RotatingFileWriter, error) {
+	//nolint:exhaustruct
+	rotatingWriter := &RotatingFileWriter{
+		path: path,
+	}
+	for _, opt := range opts {
+		opt(rotatingWriter)
+	}
+	if err := rotatingWriter.open(); err != nil {
+		return nil, err
+	}
+	if rotatingWriter.reopenOnSignal {
+		rotatingWriter.signals = make(chan os.Signal, 1)
+		notifyReopen(rotatingWriter.signals)
+		go rotatingWriter.watchSignals(rotatingWriter.signals)
+	}
+	return rotatingWriter, nil
+}
+
+// Write writes the given input to the current file, rotating it first if needed. If a previous rotation left
+// no file open, the file is opened again at the configured path.
+func (w *RotatingFileWriter) Write(input []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.ensureOpen(); err != nil {
+		return 0, err
+	}
+	if w.shouldRotate(len(input)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	numBytes, err := w.file.Write(input)
+	w.size += int64(numBytes)
+	return numBytes, err //nolint:wrapcheck
+}
+
+// Rotate closes the current file, renames it as a backup and opens a new one.
+func (w *RotatingFileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.ensureOpen(); err != nil {
+		return err
+	}
+	return w.rotate()
+}
+
+// Reopen closes and reopens the file at the configured path without renaming it. It is meant to be called
+// after an external tool has moved the file away. The current file is only closed once the new one is open, so
+// writes keep going to it if the path cannot be opened.
+func (w *RotatingFileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	previous := w.file
+	if err := w.open(); err != nil {
+		return err
+	}
+	if previous == nil {
+		return nil
+	}
+	return previous.Close() //nolint:wrapcheck
+}
+
+// Close closes the current file and waits for any background compression or cleanup to finish.
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	if w.signals != nil {
+		signal.Stop(w.signals)
+		close(w.signals)
+		w.signals = nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.millWG.Wait()
+	return err //nolint:wrapcheck
+}
+
+func (w *RotatingFileWriter) watchSignals(signals <-chan os.Signal) {
+	for range signals {
+		if err := w.Reopen(); err != nil && !errors.Is(err, os.ErrClosed) {
+			_, _ = fmt.Fprintf(os.Stderr, "rotatingFileWriter error: %v\n", err)
+		}
+	}
+}
+
+func (w *RotatingFileWriter) shouldRotate(incoming int) bool {
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(incoming) > w.maxSize {
+		return true
+	}
+	return w.interval > 0 && !time.Now().Before(w.nextRotation)
+}
+
+// ensureOpen opens the file again if a failed rotation left it closed.
+func (w *RotatingFileWriter) ensureOpen() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file == nil {
+		return w.open()
+	}
+	return nil
+}
+
+func (w *RotatingFileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), defaultDirMode); err != nil {
+		return err //nolint:wrapcheck
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileMode)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err //nolint:wrapcheck
+	}
+	w.file = file
+	w.size = info.Size()
+	if w.interval > 0 {
+		w.nextRotation = time.Now().Truncate(w.interval).Add(w.interval)
+	}
+	return nil
+}
+
+// rotate closes the file, renames it as a backup and opens a new one. The file is closed first as some platforms
+// cannot rename open files. When the rename fails, the file is opened again at its path and kept; when the new
+// file cannot be opened, no file is left open and the next write tries again.
+func (w *RotatingFileWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	backup := w.backupName(time.Now())
+	if err = os.Rename(w.path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Join(err, w.open())
+	}
+	if err = w.open(); err != nil {
+		return err
+	}
+	w.millWG.Add(1)
+	go w.mill(backup)
+	return nil
+}
+
+// backupName returns a free name for a backup rotated at the given time.
+func (w *RotatingFileWriter) backupName(timestamp time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	base := prefix + "-" + timestamp.UTC().Format(backupTimeFormat)
+	name := filepath.Join(dir, base+ext)
+	for idx := 1; fileExists(name) || fileExists(name+compressedSuffix); idx++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, idx, ext))
+	}
+	return name
+}
+
+func (w *RotatingFileWriter) nameParts() (string, string, string) {
+	dir, file := filepath.Split(w.path)
+	ext := filepath.Ext(file)
+	return dir, strings.TrimSuffix(file, ext), ext
+}
+
+// mill compresses the given backup if enabled and enforces the retention policies. It runs in the
+// background and is serialized so two rotations never work on the same files.
+func (w *RotatingFileWriter) mill(backup string) {
+	defer w.millWG.Done()
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+	if w.compress {
+		// The backup may already be gone if a later rotation applied the retention policies first.
+		if err := compressFile(backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+			_, _ = fmt.Fprintf(os.Stderr, "rotatingFileWriter error: %v\n", err)
+		}
+	}
+	if err := w.removeExpiredBackups(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "rotatingFileWriter error: %v\n", err)
+	}
+}
+
+type backupFile struct {
+	timestamp time.Time
+	path      string
+	index     int
+}
+
+func (w *RotatingFileWriter) removeExpiredBackups() error {
+	if w.maxBackups <= 0 && w.maxAge <= 0 {
+		return nil
+	}
+	backups, err := w.listBackups()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-w.maxAge)
+	for idx, backup := range backups {
+		expired := w.maxAge > 0 && backup.timestamp.Before(cutoff)
+		if expired || (w.maxBackups > 0 && idx >= w.maxBackups) {
+			if err = os.Remove(backup.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err //nolint:wrapcheck
+			}
+		}
+	}
+	return nil
+}
+
+// listBackups returns the backups of the file sorted from newest to oldest.
+func (w *RotatingFileWriter) listBackups() ([]backupFile, error) {
+	dir, prefix, ext := w.nameParts()
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	backups := make([]backupFile, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), compressedSuffix)
+		if entry.IsDir() || !strings.HasPrefix(name, prefix+"-") || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix+"-"), ext)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		timestamp, err := time.Parse(backupTimeFormat, stamp[:len(backupTimeFormat)])
+		if err != nil {
+			continue
+		}
+		// Backups rotated within the same millisecond carry an increasing ".N" suffix after the timestamp.
+		index, _ := strconv.Atoi(strings.TrimPrefix(stamp[len(backupTimeFormat):], "."))
+		backups = append(backups, backupFile{timestamp: timestamp, path: filepath.Join(dir, entry.Name()), index: index})
+	}
+	slices.SortFunc(backups, func(a, b backupFile) int {
+		if cmp := b.timestamp.Compare(a.timestamp); cmp != 0 {
+			return cmp
+		}
+		return b.index - a.index
+	})
+	return backups, nil
+}
+
+// compressFile gzips the given file next to it and removes the original once the compressed copy is complete.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer src.Close() //nolint:errcheck
+	partial := path + compressedSuffix + compressedPartial
+	dst, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFileMode)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	gzipWriter := gzip.NewWriter(dst)
+	_, err = io.Copy(gzipWriter, src)
+	err = errors.Join(err, gzipWriter.Close(), dst.Close())
+	if err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	if err = os.Rename(partial, path+compressedSuffix); err != nil {
+		return err //nolint:wrapcheck
+	}
+	return os.Remove(path) //nolint:wrapcheck
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package uslogs
+
+import "os"
+
+// notifyReopen does nothing on platforms without SIGHUP.
+func notifyReopen(_ chan<- os.Signal) {}
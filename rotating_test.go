@@ -0,0 +1,227 @@
+package uslogs_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestRotatingFileWriter_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := uslogs.NewRotatingFileWriter(path, uslogs.WithMaxSize(10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _ = w.Write([]byte("aaaaaaaa\n"))
+	_, _ = w.Write([]byte("bbbbbbbb\n"))
+	_ = w.Close()
+
+	names := listDir(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("got files %v, expected the current file and one backup", names)
+	}
+	current, _ := os.ReadFile(path)
+	if string(current) != "bbbbbbbb\n" {
+		t.Fatalf("got %q in current file, expected %q", current, "bbbbbbbb\n")
+	}
+	for _, name := range names {
+		if name != "app.log" && (!strings.HasPrefix(name, "app-") || !strings.HasSuffix(name, ".log")) {
+			t.Fatalf("unexpected backup name %q", name)
+		}
+	}
+}
+
+func TestRotatingFileWriter_RotatesByInterval(t *testing.T) {
+	dir := t.TempDir()
+	w, err := uslogs.NewRotatingFileWriter(filepath.Join(dir, "app.log"), uslogs.WithRotationInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _ = w.Write([]byte("first\n"))
+	time.Sleep(30 * time.Millisecond)
+	_, _ = w.Write([]byte("second\n"))
+	_ = w.Close()
+
+	if names := listDir(t, dir); len(names) != 2 {
+		t.Fatalf("got files %v, expected the current file and one backup", names)
+	}
+}
+
+func TestRotatingFileWriter_MaxBackupsAndCompression(t *testing.T) {
+	dir := t.TempDir()
+	w, err := uslogs.NewRotatingFileWriter(filepath.Join(dir, "app.log"),
+		uslogs.WithMaxBackups(2), uslogs.WithCompression())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for range 4 {
+		_, _ = w.Write([]byte("line\n"))
+		if err = w.Rotate(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	_ = w.Close()
+
+	names := listDir(t, dir)
+	if len(names) != 3 {
+		t.Fatalf("got files %v, expected the current file and two backups", names)
+	}
+	for _, name := range names {
+		if name == "app.log" {
+			continue
+		}
+		if !strings.HasSuffix(name, ".log.gz") {
+			t.Fatalf("backup %q is not compressed", name)
+		}
+		file, _ := os.Open(filepath.Join(dir, name))
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		content, _ := io.ReadAll(reader)
+		_ = file.Close()
+		if string(content) != "line\n" {
+			t.Fatalf("got %q in backup, expected %q", content, "line\n")
+		}
+	}
+}
+
+func TestRotatingFileWriter_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "app-2000-01-01T00-00-00.000.log")
+	if err := os.WriteFile(stale, []byte("old\n"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w, err := uslogs.NewRotatingFileWriter(filepath.Join(dir, "app.log"), uslogs.WithMaxAge(24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _ = w.Write([]byte("line\n"))
+	_ = w.Rotate()
+	_ = w.Close()
+
+	if _, err = os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale backup to be removed, got %v", err)
+	}
+	if names := listDir(t, dir); len(names) != 2 {
+		t.Fatalf("got files %v, expected the current file and one backup", names)
+	}
+}
+
+// breakDir replaces the given directory by a regular file, so nothing can be created or renamed in it, and
+// returns the function that restores it.
+func breakDir(t *testing.T, dir string) func() {
+	t.Helper()
+	if err := os.Rename(dir, dir+".moved"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(dir, nil, 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return func() {
+		if err := os.Remove(dir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.Rename(dir+".moved", dir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestRotatingFileWriter_WritesAfterFailedReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "app.log")
+	w, err := uslogs.NewRotatingFileWriter(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	_, _ = w.Write([]byte("before\n"))
+	restore := breakDir(t, dir)
+	if err = w.Reopen(); err == nil {
+		t.Fatal("expected the reopen to fail")
+	}
+	if _, err = w.Write([]byte("after\n")); err != nil {
+		t.Fatalf("got %v, expected writes to go on to the previous file", err)
+	}
+	restore()
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "before\nafter\n" {
+		t.Fatalf("got %q, expected %q", content, "before\nafter\n")
+	}
+}
+
+func TestRotatingFileWriter_WritesAfterFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "app.log")
+	w, err := uslogs.NewRotatingFileWriter(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	_, _ = w.Write([]byte("before\n"))
+	restore := breakDir(t, dir)
+	if err = w.Rotate(); err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+	if _, err = w.Write([]byte("lost\n")); err == nil {
+		t.Fatal("expected the write to fail while the directory is missing")
+	}
+	restore()
+	if _, err = w.Write([]byte("after\n")); err != nil {
+		t.Fatalf("got %v, expected the file to be opened again", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "before\nafter\n" {
+		t.Fatalf("got %q, expected %q", content, "before\nafter\n")
+	}
+}
+
+func TestRotatingFileWriter_WithAsyncWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	rotatingWriter, err := uslogs.NewRotatingFileWriter(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := uslogs.NewAsyncWriter(rotatingWriter, 10)
+
+	_, _ = w.Write([]byte("async\n"))
+	_ = w.Close()
+
+	if _, err = rotatingWriter.Write([]byte("late\n")); err == nil {
+		t.Fatal("expected the rotating writer to be closed with the async writer")
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "async\n" {
+		t.Fatalf("got %q, expected %q", content, "async\n")
+	}
+}
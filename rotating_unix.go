@@ -0,0 +1,14 @@
+//go:build unix
+
+package uslogs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyReopen relays SIGHUP to the given channel.
+func notifyReopen(signals chan<- os.Signal) {
+	signal.Notify(signals, syscall.SIGHUP)
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package uslogs_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestRotatingFileWriter_ReopenOnSIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := uslogs.NewRotatingFileWriter(path, uslogs.WithReopenOnSIGHUP())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	_, _ = w.Write([]byte("before\n"))
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	process, _ := os.FindProcess(os.Getpid())
+	if err = process.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, err = os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	_, _ = w.Write([]byte("after\n"))
+
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "after\n" {
+		t.Fatalf("got %q, %v in reopened file, expected %q", content, err, "after\n")
+	}
+}
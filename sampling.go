@@ -0,0 +1,210 @@
+package uslogs
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	samplingCounters = 4096
+	// samplingCountBits is the width of the count in the state of a samplingCounter.
+	samplingCountBits = 32
+	samplingCountMask = 1<<samplingCountBits - 1
+	fnvOffset         = 14695981039346656037
+	fnvPrime          = 1099511628211
+)
+
+// SamplingHook is called for every record dropped by a SamplingHandler, with the number of records with the
+// same level and message dropped so far in the current interval.
+type SamplingHook = func(record slog.Record, dropped uint64)
+
+// SamplingOption represents a function that configures a SamplingHandler.
+type SamplingOption = func(h *SamplingHandler)
+
+// WithLevelSampling overrides the sampling rate of records with the given level.
+func WithLevelSampling(level slog.Level, first, thereafter int) SamplingOption {
+	return func(samplingHandler *SamplingHandler) {
+		rate := newLevelSampler(level, first, thereafter)
+		samplingHandler.sampler.levels = append(samplingHandler.sampler.levels, rate)
+	}
+}
+
+// WithSamplingExemption never samples records at or above the given level.
+func WithSamplingExemption(level slog.Level) SamplingOption {
+	return func(samplingHandler *SamplingHandler) {
+		samplingHandler.sampler.exempt = true
+		samplingHandler.sampler.exemptLevel = level
+	}
+}
+
+// WithSamplingHook sets the hook called for every dropped record.
+func WithSamplingHook(hook SamplingHook) SamplingOption {
+	return func(samplingHandler *SamplingHandler) {
+		samplingHandler.sampler.hook = hook
+	}
+}
+
+// SamplingHandler is a slog.Handler that caps the volume of repeated records before handing them to the next
+// handler.
+//
+// Within every interval, it passes the first records with a given level and message, then one out of every
+// thereafter records, and drops the rest. Counters live in a fixed table indexed by a hash of the level and
+// message, so records with different messages rarely contend and sampling never allocates. Handlers derived
+// with WithAttrs and WithGroup share the counters.
+type SamplingHandler struct {
+	next    slog.Handler
+	sampler *sampler
+}
+
+type sampler struct {
+	hook        SamplingHook
+	fallback    *levelSampler
+	levels      []*levelSampler
+	interval    int64
+	exemptLevel slog.Level
+	exempt      bool
+}
+
+type levelSampler struct {
+	counters   *[samplingCounters]samplingCounter
+	first      uint64
+	thereafter uint64
+	level      slog.Level
+}
+
+type samplingCounter struct {
+	resetAt atomic.Int64
+	// state holds the low 32 bits of the resetAt of its interval above the count within it, so an interval is
+	// started and counted in with the same compare-and-swap.
+	state atomic.Uint64
+}
+
+// NewSamplingHandler creates a new SamplingHandler instance in front of the given handler. Within every
+// interval, it passes the first records of each level and message, then every thereafter-th one. A thereafter
+// of zero or less drops every record after the first ones.
+func NewSamplingHandler(
+	next slog.Handler, interval time.Duration, first, thereafter int, opts ...SamplingOption,
+) *SamplingHandler {
+	//nolint:exhaustruct
+	samplingHandler := &SamplingHandler{
+		next: next,
+		sampler: &sampler{
+			interval: int64(interval),
+			fallback: newLevelSampler(0, first, thereafter),
+		},
+	}
+	for _, opt := range opts {
+		opt(samplingHandler)
+	}
+	return samplingHandler
+}
+
+func newLevelSampler(level slog.Level, first, thereafter int) *levelSampler {
+	return &levelSampler{
+		counters:   new([samplingCounters]samplingCounter),
+		first:      uint64(max(first, 0)),      //nolint:gosec
+		thereafter: uint64(max(thereafter, 0)), //nolint:gosec
+		level:      level,
+	}
+}
+
+// Enabled returns the result of the next handler.
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record to the next handler unless it is sampled out.
+func (h *SamplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if !h.sampler.sample(record) {
+		return nil
+	}
+	return h.next.Handle(ctx, record) //nolint:wrapcheck
+}
+
+// WithAttrs returns a SamplingHandler in front of the next handler with the given attributes.
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{next: h.next.WithAttrs(attrs), sampler: h.sampler}
+}
+
+// WithGroup returns a SamplingHandler in front of the next handler with the given group.
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{next: h.next.WithGroup(name), sampler: h.sampler}
+}
+
+func (s *sampler) sample(record slog.Record) bool {
+	if s.exempt && record.Level >= s.exemptLevel {
+		return true
+	}
+	rate := s.rate(record.Level)
+	now := record.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	counter := &rate.counters[samplingHash(record.Level, record.Message)%samplingCounters]
+	count := counter.inc(now.UnixNano(), s.interval)
+	if count <= rate.first || (rate.thereafter > 0 && (count-rate.first)%rate.thereafter == 0) {
+		return true
+	}
+	if s.hook != nil {
+		dropped := count - rate.first
+		if rate.thereafter > 0 {
+			dropped -= dropped / rate.thereafter
+		}
+		s.hook(record, dropped)
+	}
+	return false
+}
+
+func (s *sampler) rate(level slog.Level) *levelSampler {
+	for _, rate := range s.levels {
+		if rate.level == level {
+			return rate
+		}
+	}
+	return s.fallback
+}
+
+// inc counts a record at the given time and returns the count within the current interval, starting a new
+// interval when the previous one is over.
+func (c *samplingCounter) inc(now int64, interval int64) uint64 {
+	resetAt := c.resetAt.Load()
+	if now >= resetAt {
+		if c.resetAt.CompareAndSwap(resetAt, now+interval) {
+			resetAt = now + interval
+		} else {
+			// Another goroutine started the new interval first.
+			resetAt = c.resetAt.Load()
+		}
+	}
+	id := uint32(resetAt) //nolint:gosec
+	for {
+		state := c.state.Load()
+		if uint32(state>>samplingCountBits) == id {
+			count := state & samplingCountMask
+			if count == samplingCountMask || c.state.CompareAndSwap(state, state+1) {
+				return min(count+1, samplingCountMask)
+			}
+			continue
+		}
+		if current := uint32(c.resetAt.Load()); current != id { //nolint:gosec
+			// The interval changed since resetAt was read, so the record counts in the new one.
+			id = current
+			continue
+		}
+		if c.state.CompareAndSwap(state, uint64(id)<<samplingCountBits|1) {
+			return 1
+		}
+	}
+}
+
+// samplingHash returns the FNV-1a hash of the level and message.
+func samplingHash(level slog.Level, message string) uint64 {
+	hash := uint64(fnvOffset)
+	hash = (hash ^ uint64(level&0xff)) * fnvPrime //nolint:gosec,mnd
+	for idx := range len(message) {
+		hash = (hash ^ uint64(message[idx])) * fnvPrime
+	}
+	return hash
+}
@@ -0,0 +1,121 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestSamplingHandler_FirstThenThereafter(t *testing.T) {
+	var out bytes.Buffer
+	var dropped []uint64
+	handler := uslogs.NewSamplingHandler(uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out)), time.Minute, 2, 3,
+		uslogs.WithSamplingHook(func(_ slog.Record, count uint64) {
+			dropped = append(dropped, count)
+		}))
+	logger := slog.New(handler).With("service", "api")
+
+	for idx := range 9 {
+		logger.Info("polling", "attempt", idx)
+	}
+	logger.Info("other")
+
+	expected := "INFO polling service=api attempt=0\n" +
+		"INFO polling service=api attempt=1\n" +
+		"INFO polling service=api attempt=4\n" +
+		"INFO polling service=api attempt=7\n" +
+		"INFO other service=api\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+	if len(dropped) != 5 || dropped[4] != 5 {
+		t.Fatalf("got dropped counts %v, expected 1 to 5", dropped)
+	}
+}
+
+func TestSamplingHandler_Interval(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewSamplingHandler(uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out)),
+		20*time.Millisecond, 1, 0))
+
+	logger.Info("tick")
+	logger.Info("tick")
+	time.Sleep(40 * time.Millisecond)
+	logger.Info("tick")
+
+	if out.String() != "INFO tick\nINFO tick\n" {
+		t.Fatalf("got %q, expected one line per interval", out.String())
+	}
+}
+
+func TestSamplingHandler_LevelRatesAndExemption(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewSamplingHandler(
+		uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out), uslogs.WithLevel(slog.LevelDebug)),
+		time.Minute, 1, 0,
+		uslogs.WithLevelSampling(slog.LevelWarn, 2, 0),
+		uslogs.WithSamplingExemption(slog.LevelError)))
+
+	for range 3 {
+		logger.Info("info")
+		logger.Warn("warn")
+		logger.Error("error")
+	}
+
+	if strings.Count(out.String(), "INFO") != 1 || strings.Count(out.String(), "WARN") != 2 ||
+		strings.Count(out.String(), "ERROR") != 3 {
+		t.Fatalf("got %q, expected 1 info, 2 warn and 3 error lines", out.String())
+	}
+}
+
+func TestSamplingHandler_Concurrent(t *testing.T) {
+	var out lockedBuffer
+	logger := slog.New(uslogs.NewSamplingHandler(uslogs.NewUnstructuredHandler(uslogs.WithWriter(&out)),
+		time.Minute, 100, 0))
+	// The first record starts the interval, so the goroutines only race on the counter.
+	logger.Info("busy")
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				logger.Info("busy")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if lines := out.Lines(); len(lines) != 100 {
+		t.Fatalf("got %d lines, expected 100", len(lines))
+	}
+}
+
+func TestSamplingHandler_ConcurrentCountsAreNotLost(t *testing.T) {
+	//nolint:exhaustruct
+	out := &lockedBuffer{}
+	logger := slog.New(uslogs.NewSamplingHandler(uslogs.NewUnstructuredHandler(uslogs.WithWriter(out)),
+		time.Hour, 400, 0))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				logger.Info("burst")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if lines := out.Lines(); len(lines) != 400 {
+		t.Fatalf("got %d lines, expected exactly the first 400", len(lines))
+	}
+}
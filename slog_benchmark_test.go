@@ -137,3 +137,73 @@ func BenchmarkSlogRegularWriter_ParallelSimulatedDisk(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkSlogJSONHandler_WithAttrs(b *testing.B) {
+	//nolint:exhaustruct
+	logger := slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{})).
+		With("service", "api").WithGroup("http")
+	attrs := []any{slog.String("user", "alice"),
+		slog.Int("id", 288),
+		slog.Bool("success", true)}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		logger.Info("benchmark log with \"attrs\"", attrs...)
+	}
+}
+
+func BenchmarkUnstructuredJSONHandler_WithAttrs(b *testing.B) {
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(io.Discard),
+		uslogs.WithTimestamp(),
+		uslogs.WithFormat(uslogs.FormatJSON))).
+		With("service", "api").WithGroup("http")
+	attrs := []any{slog.String("user", "alice"),
+		slog.Int("id", 288),
+		slog.Bool("success", true)}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		logger.Info("benchmark log with \"attrs\"", attrs...)
+	}
+}
+
+func BenchmarkSlogJSONHandler_Parallel(b *testing.B) {
+	//nolint:exhaustruct
+	logger := slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{}))
+	args := []any{slog.Int("iteration", 288), slog.String("path", "/a b")}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("parallel benchmark log",
+				args...,
+			)
+		}
+	})
+}
+
+func BenchmarkUnstructuredJSONHandler_Parallel(b *testing.B) {
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(io.Discard),
+		uslogs.WithTimestamp(),
+		uslogs.WithFormat(uslogs.FormatJSON)))
+	args := []any{slog.Int("iteration", 288), slog.String("path", "/a b")}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("parallel benchmark log",
+				args...,
+			)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package uslogs
+
+import (
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const (
+	stackKey            = "stack"
+	defaultStackFrames  = 32
+	maxStackCallers     = 128
+	uslogsPackage       = "github.com/Drathveloper/uslogs"
+	stackCallersSkipped = 2
+	// stackFrameSeparator joins frames in the stack attribute, which stays on the line.
+	stackFrameSeparator = " <- "
+)
+
+// StackFilter reports whether a frame of a captured stack trace, identified by its fully qualified function
+// name, is kept.
+type StackFilter = func(function string) bool
+
+// DefaultStackFilter keeps the frames of the application: it skips the frames of the runtime, of the standard
+// library and of uslogs.
+func DefaultStackFilter(function string) bool {
+	pkg := packagePath(function)
+	if pkg == uslogsPackage || strings.HasPrefix(pkg, uslogsPackage+"/") {
+		return false
+	}
+	if pkg == "main" {
+		return true
+	}
+	firstElem, _, _ := strings.Cut(pkg, "/")
+	// Standard library import paths never have a dot in their first element.
+	return strings.Contains(firstElem, ".")
+}
+
+// packagePath returns the import path of the package of the given fully qualified function name.
+func packagePath(function string) string {
+	lastSlash := strings.LastIndexByte(function, '/')
+	dot := strings.IndexByte(function[lastSlash+1:], '.')
+	if dot < 0 {
+		return function
+	}
+	return function[:lastSlash+1+dot]
+}
+
+// stackFrame is a resolved frame of a program counter. A single program counter resolves to several frames when
+// calls were inlined.
+type stackFrame struct {
+	function string
+	source   string
+}
+
+// frameCache keeps the frames of every program counter already seen, as resolving frames allocates.
+//
+//nolint:gochecknoglobals
+var frameCache = struct {
+	frames map[uintptr][]stackFrame
+	mu     sync.RWMutex
+}{frames: make(map[uintptr][]stackFrame)} //nolint:exhaustruct
+
+// framesOf returns the frames of the given program counter, innermost first.
+func framesOf(pc uintptr) []stackFrame {
+	frameCache.mu.RLock()
+	frames, ok := frameCache.frames[pc]
+	frameCache.mu.RUnlock()
+	if ok {
+		return frames
+	}
+	callersFrames := runtime.CallersFrames([]uintptr{pc})
+	for {
+		frame, more := callersFrames.Next()
+		frames = append(frames, stackFrame{
+			function: frame.Function,
+			source:   filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line),
+		})
+		if !more {
+			break
+		}
+	}
+	frameCache.mu.Lock()
+	frameCache.frames[pc] = frames
+	frameCache.mu.Unlock()
+	return frames
+}
+
+// capturesStack reports whether a stack trace is captured for the given record: its level is at or above the
+// stack trace level, or it has an error attribute. Records without a program counter, such as summaries, never
+// capture one.
+func (l *UnstructuredHandler) capturesStack(record slog.Record) bool {
+	if record.PC == 0 || (!l.stackTraces && !l.errorStackTraces) {
+		return false
+	}
+	if l.stackTraces && record.Level >= l.stackLevel {
+		return true
+	}
+	if !l.errorStackTraces {
+		return false
+	}
+	found := false
+	record.Attrs(func(attr slog.Attr) bool {
+		found = hasErrorValue(attr.Value)
+		return !found
+	})
+	return found
+}
+
+func hasErrorValue(value slog.Value) bool {
+	value = value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		return slices.ContainsFunc(value.Group(), func(attr slog.Attr) bool {
+			return hasErrorValue(attr.Value)
+		})
+	}
+	_, ok := value.Any().(error)
+	return value.Kind() == slog.KindAny && ok
+}
+
+// stackInBlock reports whether captured stack traces are written as a block after the line instead of as the
+// stack attribute.
+func (l *UnstructuredHandler) stackInBlock() bool {
+	return l.format == FormatText && (l.multiline == MultilineIndent || l.multiline == MultilineBlock)
+}
+
+// stackTrace captures the stack of the goroutine from the caller of the record, and returns its frames kept by
+// the stack filter as "function (file:line)", one per line when written as a block and joined by " <- "
+// otherwise.
+func (l *UnstructuredHandler) stackTrace(record slog.Record) string {
+	var pcs [maxStackCallers]uintptr
+	callers := pcs[:runtime.Callers(stackCallersSkipped, pcs[:])]
+	if idx := slices.Index(callers, record.PC); idx >= 0 {
+		callers = callers[idx:]
+	}
+	buf := logutils.SimplePool.Get().(*[]byte) //nolint:forcetypeassert
+	text := (*buf)[:0]
+	separator := stackFrameSeparator
+	if l.stackInBlock() {
+		separator = "\n"
+	}
+	count := 0
+	for _, pc := range callers {
+		for _, frame := range framesOf(pc) {
+			if count == l.stackFrames {
+				break
+			}
+			if l.stackFilter != nil && !l.stackFilter(frame.function) {
+				continue
+			}
+			if count > 0 {
+				text = append(text, separator...)
+			}
+			text = append(text, frame.function...)
+			text = append(text, " ("...)
+			text = append(text, frame.source...)
+			text = append(text, ')')
+			count++
+		}
+	}
+	stack := string(text)
+	*buf = text
+	logutils.PutPool(logutils.SimplePool, buf)
+	return stack
+}
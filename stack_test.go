@@ -0,0 +1,111 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func TestUnstructuredHandler_StackTraceAtLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithStackTrace(slog.LevelError, 0),
+	))
+
+	logger.Warn("slow")
+	logger.Error("failed", "id", 7)
+
+	expected := regexp.MustCompile(`^WARN slow\n` +
+		`ERROR failed stack=github\.com/Drathveloper/uslogs_test\.TestUnstructuredHandler_StackTraceAtLevel ` +
+		`\(stack_test\.go:\d+\) id=7\n$`)
+	if !expected.MatchString(out.String()) {
+		t.Fatalf("got %q, expected match of %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_StackTraceStaysOnLine(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithStackTrace(slog.LevelError, 2),
+		uslogs.WithStackFilter(nil),
+	))
+
+	logger.Error("failed")
+
+	expected := regexp.MustCompile(`^ERROR failed ` +
+		`stack=github\.com/Drathveloper/uslogs_test\.TestUnstructuredHandler_StackTraceStaysOnLine ` +
+		`\(stack_test\.go:\d+\) <- testing\.tRunner \(testing\.go:\d+\)\n$`)
+	if !expected.MatchString(out.String()) {
+		t.Fatalf("got %q, expected match of %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_StackTraceOnError(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithFormat(uslogs.FormatJSON),
+		uslogs.WithErrorStackTrace(),
+	))
+
+	logger.Info("retrying", slog.Group("db", slog.Any("err", errors.New("timeout"))))
+	logger.Info("done")
+
+	entries := decodeJSONLines(t, out.String())
+	stack, ok := entries[0]["stack"].(string)
+	prefix := "github.com/Drathveloper/uslogs_test.TestUnstructuredHandler_StackTraceOnError ("
+	if !ok || !strings.HasPrefix(stack, prefix) {
+		t.Fatalf("unexpected stack %q", entries[0]["stack"])
+	}
+	if _, ok = entries[1]["stack"]; ok {
+		t.Fatalf("unexpected stack in %v", entries[1])
+	}
+}
+
+func TestUnstructuredHandler_StackTraceBlock(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithStackTrace(slog.LevelError, 2),
+		uslogs.WithStackFilter(nil),
+		uslogs.WithMultiline(uslogs.MultilineIndent, "  at "),
+	))
+
+	logger.Error("failed")
+
+	expected := regexp.MustCompile(`^ERROR failed\n` +
+		`  at github\.com/Drathveloper/uslogs_test\.TestUnstructuredHandler_StackTraceBlock \(stack_test\.go:\d+\)\n` +
+		`  at testing\.tRunner \(testing\.go:\d+\)\n$`)
+	if !expected.MatchString(out.String()) {
+		t.Fatalf("got %q, expected match of %q", out.String(), expected)
+	}
+}
+
+func TestDefaultStackFilter(t *testing.T) {
+	tests := []struct {
+		function string
+		expected bool
+	}{
+		{function: "main.main", expected: true},
+		{function: "main.(*server).handle.func1", expected: true},
+		{function: "github.com/acme/app/db.(*Store).Query", expected: true},
+		{function: "github.com/Drathveloper/uslogs_test.TestX", expected: true},
+		{function: "github.com/Drathveloper/uslogs.(*UnstructuredHandler).Handle", expected: false},
+		{function: "github.com/Drathveloper/uslogs/internal/logutils.AppendValue", expected: false},
+		{function: "log/slog.(*Logger).log", expected: false},
+		{function: "net/http.(*conn).serve", expected: false},
+		{function: "runtime.goexit", expected: false},
+	}
+	for _, test := range tests {
+		if got := uslogs.DefaultStackFilter(test.function); got != test.expected {
+			t.Errorf("%s: got %t, expected %t", test.function, got, test.expected)
+		}
+	}
+}
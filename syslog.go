@@ -0,0 +1,416 @@
+package uslogs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const (
+	syslogNilValue       = '-'
+	syslogVersion        = '1'
+	defaultSyslogBackoff = 100 * time.Millisecond
+	maxSyslogBackoff     = 30 * time.Second
+	syslogDialTimeout    = 5 * time.Second
+	syslogWriteTimeout   = 5 * time.Second
+	rfc3164TimeFormat    = time.Stamp
+	rfc5424TimeFormat    = "2006-01-02T15:04:05.000000Z07:00"
+	rfc3164MaxTagLength  = 32
+	maxHostnameLength    = 255
+	maxAppNameLength     = 48
+	maxProcIDLength      = 128
+	maxMsgIDLength       = 32
+	syslogNetworkTLS     = "tcp+tls"
+	syslogNetworkUnix    = "unix"
+	syslogNetworkUDP     = "udp"
+	syslogNetworkUnixgrm = "unixgram"
+	decimalBase          = 10
+)
+
+// ErrSyslogUnavailable is returned by SyslogWriter while it waits to reconnect to the remote server.
+var ErrSyslogUnavailable = errors.New("syslog server unavailable")
+
+// SyslogFormat represents the syslog message format.
+type SyslogFormat int
+
+const (
+	// SyslogRFC5424 formats messages following RFC 5424, with structured data.
+	SyslogRFC5424 SyslogFormat = iota
+	// SyslogRFC3164 formats messages following the legacy BSD syslog format of RFC 3164.
+	SyslogRFC3164
+)
+
+// Facility represents a syslog facility.
+type Facility int
+
+// Syslog facilities as defined by RFC 5424.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFtp
+	FacilityLocal0 Facility = iota + 4
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// Syslog severities used to map slog levels.
+const (
+	severityError   = 3
+	severityWarning = 4
+	severityInfo    = 6
+	severityDebug   = 7
+)
+
+// SyslogOption represents a function that configures a SyslogWriter.
+type SyslogOption = func(w *SyslogWriter)
+
+// WithSyslogFormat sets the message format. Defaults to SyslogRFC5424.
+func WithSyslogFormat(format SyslogFormat) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.format = format
+	}
+}
+
+// WithFacility sets the facility of every message. Defaults to FacilityUser.
+func WithFacility(facility Facility) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.facility = facility
+	}
+}
+
+// WithAppName sets the APP-NAME field, or the TAG in RFC 3164. Defaults to the program name.
+func WithAppName(appName string) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.appName = appName
+	}
+}
+
+// WithProcID sets the PROCID field. Defaults to the process id.
+func WithProcID(procID string) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.procID = procID
+	}
+}
+
+// WithMsgID sets the MSGID field of RFC 5424 messages. Defaults to the nil value.
+func WithMsgID(msgID string) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.msgID = msgID
+	}
+}
+
+// WithHostname sets the HOSTNAME field. Defaults to os.Hostname.
+func WithHostname(hostname string) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.hostname = hostname
+	}
+}
+
+// WithStructuredData adds a structured data element with the given id and params to every RFC 5424 message.
+// The element is encoded once, when the option is applied.
+func WithStructuredData(id string, params ...slog.Attr) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		element := append(syslogWriter.structuredData, '[')
+		element = append(element, id...)
+		for _, param := range params {
+			element = append(element, ' ')
+			element = append(element, param.Key...)
+			element = append(element, '=', '"')
+			element = appendSDParamValue(element, param.Value.String())
+			element = append(element, '"')
+		}
+		syslogWriter.structuredData = append(element, ']')
+	}
+}
+
+// WithSyslogTLS sets the TLS configuration used by the "tcp+tls" network.
+func WithSyslogTLS(config *tls.Config) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.tlsConfig = config
+	}
+}
+
+// WithSyslogWriteTimeout sets how long a message may take to be sent before the connection is considered stalled
+// and dropped. Defaults to 5 seconds.
+func WithSyslogWriteTimeout(timeout time.Duration) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.writeTimeout = timeout
+	}
+}
+
+// WithDefaultSeverityLevel sets the level used for lines written through Write instead of WriteLevel, for
+// example when the SyslogWriter is wrapped by an AsyncWriter. Defaults to slog.LevelInfo.
+func WithDefaultSeverityLevel(level slog.Level) SyslogOption {
+	return func(syslogWriter *SyslogWriter) {
+		syslogWriter.defaultLevel = level
+	}
+}
+
+// SyslogWriter is a writer that sends every line to a syslog server.
+//
+// It supports the "udp", "tcp", "tcp+tls", "unix" and "unixgram" networks. Stream transports use octet
+// counting framing as defined by RFC 6587. When the connection fails, the writer reconnects with an
+// exponential backoff and lines written while waiting are dropped with ErrSyslogUnavailable.
+//
+// Header fields are written as printable US-ASCII, other characters being replaced by underscores, and truncated
+// to the lengths allowed by RFC 5424.
+type SyslogWriter struct {
+	conn           net.Conn
+	tlsConfig      *tls.Config
+	nextDial       time.Time
+	network        string
+	address        string
+	hostname       string
+	appName        string
+	procID         string
+	msgID          string
+	structuredData []byte
+	backoff        time.Duration
+	writeTimeout   time.Duration
+	format         SyslogFormat
+	facility       Facility
+	defaultLevel   slog.Level
+	mu             sync.Mutex
+}
+
+// NewSyslogWriter creates a new SyslogWriter instance connected to the given network and address.
+func NewSyslogWriter(network, address string, opts ...SyslogOption) (*SyslogWriter, error) {
+	hostname, _ := os.Hostname()
+	//nolint:exhaustruct
+	syslogWriter := &SyslogWriter{
+		network:      network,
+		address:      address,
+		hostname:     hostname,
+		appName:      programName(),
+		procID:       strconv.Itoa(os.Getpid()),
+		format:       SyslogRFC5424,
+		facility:     FacilityUser,
+		defaultLevel: slog.LevelInfo,
+		backoff:      defaultSyslogBackoff,
+		writeTimeout: syslogWriteTimeout,
+	}
+	for _, opt := range opts {
+		opt(syslogWriter)
+	}
+	if err := syslogWriter.dial(); err != nil {
+		return nil, err
+	}
+	return syslogWriter, nil
+}
+
+// Write sends the given line with the default severity.
+func (w *SyslogWriter) Write(input []byte) (int, error) {
+	return w.WriteLevel(w.defaultLevel, input)
+}
+
+// WriteLevel sends the given line with the severity matching the given level.
+func (w *SyslogWriter) WriteLevel(level slog.Level, input []byte) (int, error) {
+	pool := logutils.BytesPools.GetPool(len(input) + len(w.structuredData) + len(w.hostname) + len(w.appName))
+	buf := pool.Get().(*[]byte) //nolint:forcetypeassert
+	msg := w.appendMessage((*buf)[:0], level, time.Now(), bytes.TrimRight(input, "\n"))
+
+	w.mu.Lock()
+	err := w.send(msg)
+	w.mu.Unlock()
+
+	*buf = msg
+	logutils.PutPool(pool, buf)
+	if err != nil {
+		return 0, err
+	}
+	return len(input), nil
+}
+
+// Close closes the connection to the syslog server.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err //nolint:wrapcheck
+}
+
+func (w *SyslogWriter) send(msg []byte) error {
+	if w.conn == nil {
+		if time.Now().Before(w.nextDial) {
+			return ErrSyslogUnavailable
+		}
+		if err := w.dial(); err != nil {
+			return w.fail(err)
+		}
+	}
+	if err := w.write(msg); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		// The connection may have been closed by the server since the last write, so retry once
+		// on a fresh connection before backing off.
+		if err = w.dial(); err != nil {
+			return w.fail(err)
+		}
+		if err = w.write(msg); err != nil {
+			_ = w.conn.Close()
+			w.conn = nil
+			return w.fail(err)
+		}
+	}
+	w.backoff = defaultSyslogBackoff
+	return nil
+}
+
+// write sends the message with a deadline, so a stalled server cannot block the writer forever.
+func (w *SyslogWriter) write(msg []byte) error {
+	if w.writeTimeout > 0 {
+		_ = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+	_, err := w.conn.Write(msg)
+	return err //nolint:wrapcheck
+}
+
+func (w *SyslogWriter) fail(err error) error {
+	w.nextDial = time.Now().Add(w.backoff)
+	w.backoff = min(w.backoff*2, maxSyslogBackoff) //nolint:mnd
+	return err
+}
+
+func (w *SyslogWriter) dial() error {
+	var conn net.Conn
+	var err error
+	//nolint:exhaustruct
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if w.network == syslogNetworkTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.address, w.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(w.network, w.address)
+	}
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	w.conn = conn
+	return nil
+}
+
+func (w *SyslogWriter) appendMessage(dst []byte, level slog.Level, now time.Time, msg []byte) []byte {
+	framed := w.network != syslogNetworkUDP && w.network != syslogNetworkUnixgrm
+	start := len(dst)
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(w.facility)*8+int64(syslogSeverity(level)), decimalBase) //nolint:mnd
+	dst = append(dst, '>')
+	if w.format == SyslogRFC3164 {
+		dst = now.AppendFormat(dst, rfc3164TimeFormat)
+		dst = append(dst, ' ')
+		if w.network != syslogNetworkUnix && w.network != syslogNetworkUnixgrm {
+			dst = appendHeaderValue(dst, w.hostname, maxHostnameLength)
+			dst = append(dst, ' ')
+		}
+		dst = appendHeaderValue(dst, w.appName, rfc3164MaxTagLength)
+		dst = append(dst, '[')
+		dst = appendHeaderValue(dst, w.procID, maxProcIDLength)
+		dst = append(dst, ']', ':', ' ')
+	} else {
+		dst = append(dst, syslogVersion, ' ')
+		dst = now.UTC().AppendFormat(dst, rfc5424TimeFormat)
+		dst = appendHeaderField(dst, w.hostname, maxHostnameLength)
+		dst = appendHeaderField(dst, w.appName, maxAppNameLength)
+		dst = appendHeaderField(dst, w.procID, maxProcIDLength)
+		dst = appendHeaderField(dst, w.msgID, maxMsgIDLength)
+		dst = append(dst, ' ')
+		if len(w.structuredData) == 0 {
+			dst = append(dst, syslogNilValue)
+		} else {
+			dst = append(dst, w.structuredData...)
+		}
+		dst = append(dst, ' ')
+	}
+	dst = append(dst, msg...)
+	if !framed {
+		return dst
+	}
+	// Octet counting needs the length before the message, so the prefix is inserted in place.
+	var prefix [24]byte
+	length := append(strconv.AppendInt(prefix[:0], int64(len(dst)-start), decimalBase), ' ')
+	dst = append(dst, length...)
+	copy(dst[start+len(length):], dst[start:len(dst)-len(length)])
+	copy(dst[start:], length)
+	return dst
+}
+
+func appendHeaderField(dst []byte, value string, maxLength int) []byte {
+	dst = append(dst, ' ')
+	if value == "" {
+		return append(dst, syslogNilValue)
+	}
+	return appendHeaderValue(dst, value, maxLength)
+}
+
+// appendHeaderValue appends at most maxLength bytes of the value, replacing the characters that are not printable
+// US-ASCII, which RFC 5424 requires in header fields, by underscores.
+func appendHeaderValue(dst []byte, value string, maxLength int) []byte {
+	for idx := range min(len(value), maxLength) {
+		char := value[idx]
+		if char < '!' || char > '~' {
+			char = '_'
+		}
+		dst = append(dst, char)
+	}
+	return dst
+}
+
+// appendSDParamValue escapes '"', '\' and ']' as required by RFC 5424 section 6.3.3.
+func appendSDParamValue(dst []byte, value string) []byte {
+	for idx := range len(value) {
+		switch value[idx] {
+		case '"', '\\', ']':
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, value[idx])
+	}
+	return dst
+}
+
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return severityError
+	case level >= slog.LevelWarn:
+		return severityWarning
+	case level >= slog.LevelInfo:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+func programName() string {
+	name := os.Args[0]
+	for idx := len(name) - 1; idx >= 0; idx-- {
+		if os.IsPathSeparator(name[idx]) {
+			return name[idx+1:]
+		}
+	}
+	return name
+}
@@ -0,0 +1,201 @@
+package uslogs_test
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+func readOctetFramed(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	length, err := reader.ReadString(' ')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		t.Fatalf("invalid frame length %q", length)
+	}
+	msg := make([]byte, size)
+	for read := 0; read < size; {
+		n, err := reader.Read(msg[read:])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		read += n
+	}
+	return string(msg)
+}
+
+func TestSyslogWriter_RFC5424OverTCP(t *testing.T) {
+	//nolint:noctx
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		reader := bufio.NewReader(conn)
+		received <- []string{readOctetFramed(t, reader), readOctetFramed(t, reader), readOctetFramed(t, reader)}
+	}()
+
+	w, err := uslogs.NewSyslogWriter("tcp", ln.Addr().String(),
+		uslogs.WithFacility(uslogs.FacilityLocal0),
+		uslogs.WithHostname("host"),
+		uslogs.WithAppName("app"),
+		uslogs.WithProcID("42"),
+		uslogs.WithMsgID("req"),
+		uslogs.WithStructuredData("meta@1", slog.String("env", `pr"od]`)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	handler := uslogs.NewUnstructuredHandler(uslogs.WithWriter(w))
+	_ = handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelError, "boom", 0))
+	_ = handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelDebug, "quiet", 0))
+	_, _ = w.Write([]byte("plain\n"))
+
+	msgs := <-received
+	pattern := regexp.MustCompile(`^<131>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}Z host app 42 req \[meta@1 env="pr\\"od\\]"\] ERROR boom$`)
+	if !pattern.MatchString(msgs[0]) {
+		t.Fatalf("got %q, expected an RFC 5424 message with severity 3", msgs[0])
+	}
+	if !strings.HasPrefix(msgs[1], "<135>1 ") || !strings.HasSuffix(msgs[1], " DEBUG quiet") {
+		t.Fatalf("got %q, expected a debug message with severity 7", msgs[1])
+	}
+	if !strings.HasPrefix(msgs[2], "<134>1 ") || !strings.HasSuffix(msgs[2], " plain") {
+		t.Fatalf("got %q, expected an informational message without trailing newline", msgs[2])
+	}
+}
+
+func TestSyslogWriter_RFC3164OverUDP(t *testing.T) {
+	//nolint:noctx
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	w, err := uslogs.NewSyslogWriter("udp", conn.LocalAddr().String(),
+		uslogs.WithSyslogFormat(uslogs.SyslogRFC3164),
+		uslogs.WithHostname("host"),
+		uslogs.WithAppName("app"),
+		uslogs.WithProcID("42"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	if _, err = w.WriteLevel(slog.LevelWarn, []byte("careful\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pattern := regexp.MustCompile(`^<12>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2} host app\[42\]: careful$`)
+	if !pattern.Match(buf[:n]) {
+		t.Fatalf("got %q, expected an RFC 3164 message with severity 4", buf[:n])
+	}
+}
+
+func TestSyslogWriter_SanitizesHeaderFields(t *testing.T) {
+	//nolint:noctx
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	w, err := uslogs.NewSyslogWriter("udp", conn.LocalAddr().String(),
+		uslogs.WithHostname("web 1\n"),
+		uslogs.WithAppName(strings.Repeat("a", 60)),
+		uslogs.WithProcID("4é"),
+		uslogs.WithMsgID(strings.Repeat("m", 40)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	if _, err = w.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, header, _ := strings.Cut(string(buf[:n]), "Z ")
+	expected := "web_1_ " + strings.Repeat("a", 48) + " 4__ " + strings.Repeat("m", 32) + " - hello"
+	if header != expected {
+		t.Fatalf("got %q, expected %q", header, expected)
+	}
+}
+
+func TestSyslogWriter_Reconnects(t *testing.T) {
+	//nolint:noctx
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() //nolint:errcheck
+
+	received := make(chan string, 1)
+	go func() {
+		first, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = first.Close()
+		second, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer second.Close() //nolint:errcheck
+		_ = second.SetReadDeadline(time.Now().Add(2 * time.Second))
+		received <- readOctetFramed(t, bufio.NewReader(second))
+	}()
+
+	w, err := uslogs.NewSyslogWriter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	deadline := time.After(2 * time.Second)
+	for {
+		_, _ = w.Write([]byte("again\n"))
+		select {
+		case msg := <-received:
+			if !strings.HasSuffix(msg, " again") {
+				t.Fatalf("got %q, expected the message on the new connection", msg)
+			}
+			return
+		case <-deadline:
+			t.Fatal("message never reached the server after reconnecting")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package uslogs
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether the given file descriptor is a terminal, using the TIOCGETA ioctl.
+func isTerminal(fd uintptr) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGETA, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
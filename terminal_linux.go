@@ -0,0 +1,15 @@
+//go:build linux
+
+package uslogs
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether the given file descriptor is a terminal, using the TCGETS ioctl.
+func isTerminal(fd uintptr) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package uslogs
+
+// isTerminal always reports false on platforms without terminal detection.
+func isTerminal(_ uintptr) bool {
+	return false
+}
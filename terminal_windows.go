@@ -0,0 +1,11 @@
+//go:build windows
+
+package uslogs
+
+import "syscall"
+
+// isTerminal reports whether the given handle is a console.
+func isTerminal(fd uintptr) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
@@ -0,0 +1,150 @@
+package uslogs
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const (
+	traceIDKey           = "trace_id"
+	spanIDKey            = "span_id"
+	traceParentLength    = 55
+	traceParentInvalid   = "ff"
+	traceFlagSampled     = 0x01
+	traceParentVersion00 = "00"
+)
+
+// ErrInvalidTraceParent is returned by ParseTraceParent when the header is not a valid W3C traceparent.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceParent is the W3C trace context of a span: its trace id, span id and trace flags.
+type TraceParent struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// ParseTraceParent parses the value of a W3C traceparent header, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceParent(header string) (TraceParent, error) {
+	//nolint:exhaustruct
+	var traceParent TraceParent
+	if len(header) < traceParentLength || header[2] != '-' || header[35] != '-' || header[52] != '-' {
+		return traceParent, ErrInvalidTraceParent
+	}
+	version := header[:2]
+	if version == traceParentInvalid || (version == traceParentVersion00 && len(header) != traceParentLength) ||
+		(len(header) > traceParentLength && header[traceParentLength] != '-') {
+		return traceParent, ErrInvalidTraceParent
+	}
+	var flags [1]byte
+	if !decodeHex(traceParent.TraceID[:], header[3:35]) || !decodeHex(traceParent.SpanID[:], header[36:52]) ||
+		!decodeHex(flags[:], header[53:55]) || !decodeHex(nil, version) {
+		return traceParent, ErrInvalidTraceParent
+	}
+	traceParent.Flags = flags[0]
+	if !traceParent.IsValid() {
+		return traceParent, ErrInvalidTraceParent
+	}
+	return traceParent, nil
+}
+
+// IsValid reports whether both the trace id and the span id are non-zero.
+func (t TraceParent) IsValid() bool {
+	return t.TraceID != [16]byte{} && t.SpanID != [8]byte{}
+}
+
+// Sampled reports whether the sampled flag is set.
+func (t TraceParent) Sampled() bool {
+	return t.Flags&traceFlagSampled != 0
+}
+
+// TraceSource returns the trace context of the span active in a context. Applications using OpenTelemetry can
+// implement it with a few lines reading trace.SpanContextFromContext, without this module importing the SDK.
+type TraceSource interface {
+	TraceParent(ctx context.Context) (TraceParent, bool)
+}
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a copy of the given context carrying the given trace context.
+func ContextWithTraceParent(ctx context.Context, traceParent TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// ContextTraceSource is a TraceSource that reads the trace context stored with ContextWithTraceParent.
+type ContextTraceSource struct{}
+
+// TraceParent returns the trace context stored in the given context, if it is valid.
+func (ContextTraceSource) TraceParent(ctx context.Context) (TraceParent, bool) {
+	traceParent, ok := ctx.Value(traceParentKey{}).(TraceParent)
+	return traceParent, ok && traceParent.IsValid()
+}
+
+// appendTraceAttrs appends the trace and span ids of the given trace context as hexadecimal attributes. When
+// attributes are rewritten or pinned, they go through the same path as the other attributes.
+func (l *UnstructuredHandler) appendTraceAttrs(dst []byte, pins *pinnedAttrs, traceParent *TraceParent) []byte {
+	if !l.rewriting && pins == nil {
+		return l.appendTrace(dst, traceParent)
+	}
+	var attrs [2]slog.Attr
+	for _, attr := range traceEntryAttrs(attrs[:0], traceParent) {
+		dst = l.appendRewrittenAttr(dst, pins, nil, nil, attr)
+	}
+	return dst
+}
+
+// appendTrace appends the trace and span ids of the given trace context as hexadecimal attributes.
+func (l *UnstructuredHandler) appendTrace(dst []byte, traceParent *TraceParent) []byte {
+	if l.format == FormatCEF || l.format == FormatLEEF {
+		separator := byte(' ')
+		if l.format == FormatLEEF {
+			separator = leefSeparator
+		}
+		dst = append(dst, separator)
+		dst = append(dst, traceIDKey+"="...)
+		dst = logutils.AppendHex(dst, traceParent.TraceID[:])
+		dst = append(dst, separator)
+		dst = append(dst, spanIDKey+"="...)
+		return logutils.AppendHex(dst, traceParent.SpanID[:])
+	}
+	if l.format == FormatJSON {
+		dst = append(dst, `,"`+traceIDKey+`":"`...)
+		dst = logutils.AppendHex(dst, traceParent.TraceID[:])
+		dst = append(dst, `","`+spanIDKey+`":"`...)
+		dst = logutils.AppendHex(dst, traceParent.SpanID[:])
+		return append(dst, '"')
+	}
+	dst = logutils.AppendSeparator(dst, l.separator)
+	dst = append(dst, traceIDKey+"="...)
+	dst = logutils.AppendHex(dst, traceParent.TraceID[:])
+	dst = logutils.AppendSeparator(dst, l.separator)
+	dst = append(dst, spanIDKey+"="...)
+	return logutils.AppendHex(dst, traceParent.SpanID[:])
+}
+
+// traceEntryAttrs returns the trace and span ids as attributes for a RecordWriter.
+func traceEntryAttrs(dst []slog.Attr, traceParent *TraceParent) []slog.Attr {
+	return append(dst,
+		slog.String(traceIDKey, hex.EncodeToString(traceParent.TraceID[:])),
+		slog.String(spanIDKey, hex.EncodeToString(traceParent.SpanID[:])))
+}
+
+func decodeHex(dst []byte, src string) bool {
+	for idx := range len(src) {
+		char := src[idx]
+		// Only lowercase digits are allowed by the specification.
+		if (char < '0' || char > '9') && (char < 'a' || char > 'f') {
+			return false
+		}
+	}
+	if dst == nil {
+		return true
+	}
+	_, err := hex.Decode(dst, []byte(src))
+	return err == nil
+}
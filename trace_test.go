@@ -0,0 +1,102 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+const sampledTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceParent(t *testing.T) {
+	traceParent, err := uslogs.ParseTraceParent(sampledTraceParent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if traceParent.TraceID[0] != 0x4b || traceParent.SpanID[7] != 0xb7 || !traceParent.Sampled() {
+		t.Fatalf("got %+v, expected the parsed header", traceParent)
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, header := range invalid {
+		if _, err = uslogs.ParseTraceParent(header); !errors.Is(err, uslogs.ErrInvalidTraceParent) {
+			t.Errorf("ParseTraceParent(%q) = %v, expected ErrInvalidTraceParent", header, err)
+		}
+	}
+}
+
+func TestUnstructuredHandler_TraceContext(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithTraceContext(uslogs.ContextTraceSource{}),
+	)).With("service", "api")
+	traceParent, _ := uslogs.ParseTraceParent(sampledTraceParent)
+	ctx := uslogs.ContextWithTraceParent(context.Background(), traceParent)
+
+	logger.InfoContext(ctx, "served", "status", 200)
+	logger.Info("untraced")
+
+	expected := "INFO served service=api trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 status=200\n" +
+		"INFO untraced service=api\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_TraceContextRewritten(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithTraceContext(uslogs.ContextTraceSource{}),
+		uslogs.WithRenamedKeys(map[string]string{"trace_id": "trace.id"}),
+		uslogs.WithDroppedKeys("span_id"),
+		uslogs.WithPinnedKeys("trace.id"),
+	)).With("service", "api")
+	traceParent, _ := uslogs.ParseTraceParent(sampledTraceParent)
+	ctx := uslogs.ContextWithTraceParent(context.Background(), traceParent)
+
+	logger.InfoContext(ctx, "served", "status", 200)
+
+	expected := "INFO served trace.id=4bf92f3577b34da6a3ce929d0e0e4736 service=api status=200\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+func TestUnstructuredHandler_TraceSampling(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(uslogs.NewUnstructuredHandler(
+		uslogs.WithWriter(&out),
+		uslogs.WithTraceContext(uslogs.ContextTraceSource{}),
+		uslogs.WithTraceSampling(slog.LevelWarn),
+	))
+	traceParent, _ := uslogs.ParseTraceParent(sampledTraceParent)
+	sampled := uslogs.ContextWithTraceParent(context.Background(), traceParent)
+	traceParent.Flags = 0
+	unsampled := uslogs.ContextWithTraceParent(context.Background(), traceParent)
+
+	logger.InfoContext(sampled, "kept")
+	logger.InfoContext(unsampled, "dropped")
+	logger.WarnContext(unsampled, "warned")
+	logger.Info("untraced")
+
+	expected := "INFO kept trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7\n" +
+		"WARN warned trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7\n" +
+		"INFO untraced\n"
+	if out.String() != expected {
+		t.Fatalf("got %q, expected %q", out.String(), expected)
+	}
+}
@@ -1,70 +1,164 @@
 package uslogs
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/maphash"
 	"io"
+	"math/rand/v2"
 	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Drathveloper/uslogs/internal/logutils"
 )
 
 const allocatedLogSize = 64 * 1024
 
+var (
+	// ErrLineTooLong is returned by AsyncWriter when a line can never fit in its ring buffer.
+	ErrLineTooLong = errors.New("line exceeds async writer capacity")
+	// ErrBufferFull is returned by AsyncWriter configured with WithDropWhenFull when its queue is full.
+	ErrBufferFull = errors.New("async writer buffer is full")
+)
+
+// AsyncWriterOption represents a function that configures an AsyncWriter.
+type AsyncWriterOption = func(w *AsyncWriter)
+
+// WithRingBuffer replaces the channel backend with a lock-free ring buffer of the given size in bytes.
+// Lines are copied into a single shared arena instead of per-slot preallocated buffers.
+func WithRingBuffer(size int) AsyncWriterOption {
+	return func(asyncWriter *AsyncWriter) {
+		asyncWriter.ringSize = size
+	}
+}
+
+// WithDropWhenFull makes Write drop the line with ErrBufferFull when the queue is full, instead of waiting for
+// the consumer to free space.
+func WithDropWhenFull() AsyncWriterOption {
+	return func(asyncWriter *AsyncWriter) {
+		asyncWriter.dropWhenFull = true
+	}
+}
+
+// WithShards splits the queue into the given number of shards, each drained by its own consumer goroutine.
+// Unless the ordering is OrderGlobal, consumers write concurrently, so the underlying writer must be safe
+// for concurrent use. The buffer size or ring size applies to every shard. Under OrderGlobal, Write also waits,
+// or drops the line with WithDropWhenFull, while 16384 lines are in flight.
+func WithShards(shards int) AsyncWriterOption {
+	return func(asyncWriter *AsyncWriter) {
+		asyncWriter.shards = max(shards, 1)
+	}
+}
+
+// WithOrdering sets the ordering guarantee of a sharded AsyncWriter. Defaults to OrderNone.
+func WithOrdering(ordering Ordering) AsyncWriterOption {
+	return func(asyncWriter *AsyncWriter) {
+		asyncWriter.ordering = ordering
+	}
+}
+
+// asyncBackend is a queue of pending lines drained by a single consumer goroutine.
+type asyncBackend interface {
+	push(header, input []byte) error
+	run(sink func([]byte))
+	stop()
+}
+
 // AsyncWriter is a writer that asynchronously writes logs to an underlying writer.
 type AsyncWriter struct {
-	logChan  chan *[]byte
-	freeChan chan *[]byte
-	writer   io.Writer
-	closed   atomic.Bool
-	wg       sync.WaitGroup
+	writer       io.Writer
+	hook         AsyncWriterHook
+	merger       *lineMerger
+	backends     []asyncBackend
+	keySeed      maphash.Seed
+	ringSize     int
+	shards       int
+	ordering     Ordering
+	closed       atomic.Bool
+	wg           sync.WaitGroup
+	stats        asyncStats
+	dropWhenFull bool
 }
 
 // NewAsyncWriter creates a new AsyncWriter instance.
-func NewAsyncWriter(writer io.Writer, bufSize int) *AsyncWriter {
+func NewAsyncWriter(writer io.Writer, bufSize int, opts ...AsyncWriterOption) *AsyncWriter {
+	//nolint:exhaustruct
 	asyncWriter := &AsyncWriter{
-		logChan:  make(chan *[]byte, bufSize),
-		freeChan: make(chan *[]byte, bufSize),
 		writer:   writer,
+		shards:   1,
+		ordering: OrderNone,
 		wg:       sync.WaitGroup{},
 		closed:   atomic.Bool{},
+		keySeed:  maphash.MakeSeed(),
 	}
-	for range bufSize {
-		b := make([]byte, 0, allocatedLogSize)
-		asyncWriter.freeChan <- &b
-	}
-	asyncWriter.wg.Add(1)
-	go func() {
-		for buf := range asyncWriter.logChan {
-			numBytes, err := writer.Write(*buf)
-			if err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "asyncWriter error: %v\n", err)
-			}
-			select {
-			case asyncWriter.freeChan <- buf:
-			default:
-				logutils.BytesPools.GetPool(numBytes).Put(buf)
-			}
+	for _, opt := range opts {
+		opt(asyncWriter)
+	}
+	sink := asyncWriter.writeLine
+	if asyncWriter.ordering == OrderGlobal {
+		asyncWriter.merger = newLineMerger(maxMergerPending)
+		sink = asyncWriter.merger.put
+		go asyncWriter.merger.run(asyncWriter.writeLine)
+	}
+	asyncWriter.backends = make([]asyncBackend, asyncWriter.shards)
+	for idx := range asyncWriter.backends {
+		var backend asyncBackend
+		if asyncWriter.ringSize > 0 {
+			backend = newRingBackend(asyncWriter.ringSize, asyncWriter.dropWhenFull)
+		} else {
+			backend = newChannelBackend(bufSize, &asyncWriter.stats, asyncWriter.dropWhenFull)
 		}
-		asyncWriter.wg.Done()
-	}()
+		asyncWriter.backends[idx] = backend
+		asyncWriter.wg.Add(1)
+		go func() {
+			backend.run(sink)
+			asyncWriter.wg.Done()
+		}()
+	}
 	return asyncWriter
 }
 
 // Write writes the given input to the underlying writer.
 func (w *AsyncWriter) Write(input []byte) (int, error) {
+	return w.write(w.shard(""), input)
+}
+
+// WriteKeyed writes the given input to the underlying writer. Under OrderPerKey, inputs written with the same
+// non-empty key go through the same shard, so they are written in order.
+func (w *AsyncWriter) WriteKeyed(key string, input []byte) (int, error) {
+	return w.write(w.shard(key), input)
+}
+
+func (w *AsyncWriter) write(shard int, input []byte) (int, error) {
 	if w.closed.Load() {
+		w.drop(len(input), io.ErrClosedPipe)
 		return 0, io.ErrClosedPipe
 	}
-	var buf *[]byte
-	select {
-	case buf = <-w.freeChan:
-	default:
-		buf = logutils.BytesPools.GetPool(len(input)).Get().(*[]byte) //nolint:forcetypeassert
+	backend := w.backends[shard]
+	if w.merger == nil {
+		if err := backend.push(nil, input); err != nil {
+			w.drop(len(input), err)
+			return 0, err
+		}
+		w.stats.enqueued.Add(1)
+		return len(input), nil
 	}
-	*buf = append((*buf)[:0], input...)
-	w.logChan <- buf
+	seq, err := w.merger.reserve(!w.dropWhenFull)
+	if err != nil {
+		w.drop(len(input), err)
+		return 0, err
+	}
+	var header [seqHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:], seq)
+	if err = backend.push(header[:], input); err != nil {
+		w.merger.skip(seq)
+		w.drop(len(input), err)
+		return 0, err
+	}
+	w.stats.enqueued.Add(1)
 	return len(input), nil
 }
 
@@ -74,12 +168,164 @@ func (w *AsyncWriter) Close() error {
 		return nil
 	}
 
-	close(w.logChan)
+	for _, backend := range w.backends {
+		backend.stop()
+	}
 
 	w.wg.Wait()
+	if w.merger != nil {
+		w.merger.finish()
+	}
 	if c, ok := w.writer.(io.Closer); ok {
 		return c.Close() //nolint: wrapcheck
 	}
 
 	return nil
 }
+
+func (w *AsyncWriter) shard(key string) int {
+	if w.shards == 1 {
+		return 0
+	}
+	if w.ordering == OrderPerKey && key != "" {
+		return int(maphash.String(w.keySeed, key) % uint64(w.shards)) //nolint:gosec
+	}
+	return rand.IntN(w.shards) //nolint:gosec
+}
+
+func (w *AsyncWriter) writeLine(line []byte) {
+	timed := w.hook != nil || w.stats.sampled()
+	var start time.Time
+	if timed {
+		start = time.Now()
+	}
+	numBytes, err := w.writer.Write(line)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "asyncWriter error: %v\n", err)
+	}
+	w.stats.written(numBytes, err)
+	if !timed {
+		return
+	}
+	latency := time.Since(start)
+	w.stats.observe(latency)
+	if w.hook != nil {
+		w.hook.OnWrite(numBytes, latency, err)
+	}
+}
+
+func (w *AsyncWriter) drop(numBytes int, err error) {
+	w.stats.drops.Add(1)
+	if w.hook != nil {
+		w.hook.OnDrop(numBytes, err)
+	}
+}
+
+// channelBackend hands preallocated buffers to the consumer through a channel.
+type channelBackend struct {
+	logChan      chan *[]byte
+	freeChan     chan *[]byte
+	stats        *asyncStats
+	dropWhenFull bool
+}
+
+func newChannelBackend(bufSize int, stats *asyncStats, dropWhenFull bool) *channelBackend {
+	backend := &channelBackend{
+		logChan:      make(chan *[]byte, bufSize),
+		freeChan:     make(chan *[]byte, bufSize),
+		stats:        stats,
+		dropWhenFull: dropWhenFull,
+	}
+	for range bufSize {
+		b := make([]byte, 0, allocatedLogSize)
+		backend.freeChan <- &b
+	}
+	return backend
+}
+
+func (b *channelBackend) push(header, input []byte) error {
+	var buf *[]byte
+	select {
+	case buf = <-b.freeChan:
+	default:
+		b.stats.poolMisses.Add(1)
+		buf = logutils.BytesPools.GetPool(len(header) + len(input)).Get().(*[]byte) //nolint:forcetypeassert
+	}
+	*buf = append(append((*buf)[:0], header...), input...)
+	if !b.dropWhenFull {
+		b.logChan <- buf
+		return nil
+	}
+	select {
+	case b.logChan <- buf:
+		return nil
+	default:
+		b.release(buf)
+		return ErrBufferFull
+	}
+}
+
+func (b *channelBackend) run(sink func([]byte)) {
+	for buf := range b.logChan {
+		sink(*buf)
+		b.release(buf)
+	}
+}
+
+func (b *channelBackend) release(buf *[]byte) {
+	select {
+	case b.freeChan <- buf:
+	default:
+		logutils.BytesPools.GetPool(len(*buf)).Put(buf)
+	}
+}
+
+func (b *channelBackend) stop() {
+	close(b.logChan)
+}
+
+// ringBackend copies lines into a shared arena owned by a lock-free MPSC ring buffer.
+type ringBackend struct {
+	ring         *logutils.RingBuffer
+	stopped      atomic.Bool
+	dropWhenFull bool
+}
+
+func newRingBackend(size int, dropWhenFull bool) *ringBackend {
+	return &ringBackend{
+		ring:         logutils.NewRingBuffer(size),
+		stopped:      atomic.Bool{},
+		dropWhenFull: dropWhenFull,
+	}
+}
+
+func (b *ringBackend) push(header, input []byte) error {
+	if !b.ring.Fits(len(header) + len(input)) {
+		return ErrLineTooLong
+	}
+	if !b.dropWhenFull {
+		b.ring.Push(header, input)
+		return nil
+	}
+	if !b.ring.TryPush(header, input) {
+		return ErrBufferFull
+	}
+	return nil
+}
+
+func (b *ringBackend) run(sink func([]byte)) {
+	for {
+		if b.ring.Drain(sink) > 0 {
+			continue
+		}
+		if b.stopped.Load() && b.ring.Len() == 0 {
+			return
+		}
+		b.ring.Wait()
+	}
+}
+
+func (b *ringBackend) stop() {
+	b.stopped.Store(true)
+	b.ring.Signal()
+}
@@ -2,6 +2,7 @@ package uslogs_test
 
 import (
 	"io"
+	"runtime"
 	"testing"
 
 	"github.com/Drathveloper/uslogs"
@@ -21,3 +22,87 @@ func BenchmarkAsyncWriter(b *testing.B) {
 
 	_ = w.Close()
 }
+
+func BenchmarkAsyncWriter_RingBuffer(b *testing.B) {
+	w := uslogs.NewAsyncWriter(io.Discard, 0, uslogs.WithRingBuffer(100*1024))
+
+	data := []byte("log line example\n")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_, _ = w.Write(data)
+	}
+
+	_ = w.Close()
+}
+
+func BenchmarkAsyncWriter_Parallel(b *testing.B) {
+	w := uslogs.NewAsyncWriter(io.Discard, 1000)
+
+	data := []byte("It was a simple tip of the hat. Grace didn't think that anyone else had noticed it\n")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = w.Write(data)
+		}
+	})
+
+	_ = w.Close()
+}
+
+func BenchmarkAsyncWriter_RingBufferParallel(b *testing.B) {
+	w := uslogs.NewAsyncWriter(io.Discard, 0, uslogs.WithRingBuffer(1024*1024))
+
+	data := []byte("It was a simple tip of the hat. Grace didn't think that anyone else had noticed it\n")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = w.Write(data)
+		}
+	})
+
+	_ = w.Close()
+}
+
+func BenchmarkAsyncWriter_ShardsParallel(b *testing.B) {
+	w := uslogs.NewAsyncWriter(io.Discard, 1000, uslogs.WithShards(runtime.GOMAXPROCS(0)))
+
+	data := []byte("It was a simple tip of the hat. Grace didn't think that anyone else had noticed it\n")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = w.Write(data)
+		}
+	})
+
+	_ = w.Close()
+}
+
+func BenchmarkAsyncWriter_ShardsOrderGlobalParallel(b *testing.B) {
+	w := uslogs.NewAsyncWriter(io.Discard, 1000,
+		uslogs.WithShards(runtime.GOMAXPROCS(0)), uslogs.WithOrdering(uslogs.OrderGlobal))
+
+	data := []byte("It was a simple tip of the hat. Grace didn't think that anyone else had noticed it\n")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = w.Write(data)
+		}
+	})
+
+	_ = w.Close()
+}
@@ -0,0 +1,208 @@
+package uslogs
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/Drathveloper/uslogs/internal/logutils"
+)
+
+const (
+	// seqHeaderSize is the size of the sequence number prepended to every queued line under OrderGlobal.
+	seqHeaderSize = 8
+	// maxMergerPending is the number of lines that can be in flight under OrderGlobal, between Write and the
+	// merger writing them, so a stalled shard cannot make the merger buffer every line of the others.
+	maxMergerPending = 16 * 1024
+)
+
+// Ordering represents the ordering guarantee of a sharded AsyncWriter.
+type Ordering int
+
+const (
+	// OrderNone spreads lines across random shards and gives no ordering guarantee between them.
+	OrderNone Ordering = iota
+	// OrderPerKey sends the lines logged with the same ordering key, set on their context with
+	// ContextWithOrderingKey, to the same shard, so they keep their order. Lines without a key are spread across
+	// random shards.
+	OrderPerKey
+	// OrderGlobal stamps every line with a sequence number and merges the shards back in that order
+	// before writing, so lines are written exactly in the order Write was called.
+	OrderGlobal
+)
+
+type orderingKey struct{}
+
+// ContextWithOrderingKey returns a copy of the given context carrying the given ordering key. Under OrderPerKey,
+// the lines logged with it by an UnstructuredHandler writing to an AsyncWriter keep their order. An empty key
+// is no key.
+func ContextWithOrderingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, orderingKey{}, key)
+}
+
+// OrderingKeyFromContext returns the ordering key carried by the given context, or an empty string if there is
+// none.
+func OrderingKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(orderingKey{}).(string)
+	return key
+}
+
+// lineMerger reorders lines coming from several shard consumers by their sequence number and writes them
+// from a single goroutine. At most capacity sequence numbers are handed out ahead of the next line to write.
+type lineMerger struct {
+	cond     *sync.Cond
+	room     *sync.Cond
+	done     chan struct{}
+	pending  lineHeap
+	next     uint64
+	issued   uint64
+	capacity uint64
+	waiters  int
+	mu       sync.Mutex
+	closing  bool
+}
+
+func newLineMerger(capacity int) *lineMerger {
+	//nolint:exhaustruct
+	merger := &lineMerger{
+		done:     make(chan struct{}),
+		pending:  make(lineHeap, 0),
+		next:     1,
+		capacity: uint64(max(capacity, 1)), //nolint:gosec
+	}
+	merger.cond = sync.NewCond(&merger.mu)
+	merger.room = sync.NewCond(&merger.mu)
+	return merger
+}
+
+// reserve hands out the next sequence number. When capacity lines are already in flight, it waits for the
+// merger to write some of them, or fails with ErrBufferFull if wait is false.
+func (m *lineMerger) reserve(wait bool) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for m.issued-(m.next-1) >= m.capacity {
+		if m.closing {
+			return 0, io.ErrClosedPipe
+		}
+		if !wait {
+			return 0, ErrBufferFull
+		}
+		m.waiters++
+		m.room.Wait()
+		m.waiters--
+	}
+	m.issued++
+	return m.issued, nil
+}
+
+// put receives a line prefixed with its sequence number. The line is copied because the shard
+// releases its buffer as soon as put returns.
+func (m *lineMerger) put(line []byte) {
+	seq := binary.BigEndian.Uint64(line)
+	line = line[seqHeaderSize:]
+	buf := logutils.BytesPools.GetPool(len(line)).Get().(*[]byte) //nolint:forcetypeassert
+	*buf = append((*buf)[:0], line...)
+	m.add(pendingLine{seq: seq, buf: buf})
+}
+
+// skip releases a sequence number whose line never reached a shard.
+func (m *lineMerger) skip(seq uint64) {
+	m.add(pendingLine{seq: seq, buf: nil})
+}
+
+func (m *lineMerger) add(line pendingLine) {
+	m.mu.Lock()
+	m.pending.push(line)
+	if line.seq == m.next {
+		m.cond.Signal()
+	}
+	m.mu.Unlock()
+}
+
+// run writes lines in sequence order until finish is called. Once finishing, any remaining line is
+// written in order even if a sequence number is missing.
+func (m *lineMerger) run(sink func([]byte)) {
+	m.mu.Lock()
+	for {
+		for len(m.pending) > 0 && (m.pending[0].seq == m.next || m.closing) {
+			line := m.pending.pop()
+			m.next = line.seq + 1
+			if m.waiters > 0 {
+				m.room.Broadcast()
+			}
+			if line.buf == nil {
+				continue
+			}
+			m.mu.Unlock()
+			sink(*line.buf)
+			logutils.PutPool(logutils.BytesPools.GetPool(len(*line.buf)), line.buf)
+			m.mu.Lock()
+		}
+		if m.closing {
+			break
+		}
+		m.cond.Wait()
+	}
+	m.mu.Unlock()
+	close(m.done)
+}
+
+// finish makes run flush every pending line and return. It must be called once all shards are drained.
+func (m *lineMerger) finish() {
+	m.mu.Lock()
+	m.closing = true
+	m.cond.Signal()
+	m.room.Broadcast()
+	m.mu.Unlock()
+	<-m.done
+}
+
+type pendingLine struct {
+	buf *[]byte
+	seq uint64
+}
+
+// lineHeap is a min-heap of pending lines ordered by sequence number. It is hand-rolled instead of
+// using container/heap to avoid boxing every line in an interface.
+type lineHeap []pendingLine
+
+func (h *lineHeap) push(line pendingLine) {
+	*h = append(*h, line)
+	items := *h
+	idx := len(items) - 1
+	for idx > 0 {
+		parent := (idx - 1) / 2 //nolint:mnd
+		if items[parent].seq <= items[idx].seq {
+			break
+		}
+		items[parent], items[idx] = items[idx], items[parent]
+		idx = parent
+	}
+}
+
+func (h *lineHeap) pop() pendingLine {
+	items := *h
+	top := items[0]
+	last := len(items) - 1
+	items[0] = items[last]
+	items = items[:last]
+	idx := 0
+	for {
+		smallest := idx
+		left, right := 2*idx+1, 2*idx+2 //nolint:mnd
+		if left < len(items) && items[left].seq < items[smallest].seq {
+			smallest = left
+		}
+		if right < len(items) && items[right].seq < items[smallest].seq {
+			smallest = right
+		}
+		if smallest == idx {
+			break
+		}
+		items[idx], items[smallest] = items[smallest], items[idx]
+		idx = smallest
+	}
+	*h = items
+	return top
+}
@@ -0,0 +1,139 @@
+package uslogs
+
+import (
+	"expvar"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencyBuckets is the number of buckets of the write latency histogram. Bucket i counts writes that
+	// took less than 2^i microseconds; the last bucket counts everything slower.
+	latencyBuckets = 20
+
+	// latencySampleRate is how often writes are timed when no hook is registered. Reading the clock
+	// twice per line would make the consumer the bottleneck on fast writers.
+	latencySampleRate = 64
+)
+
+// AsyncWriterStats is a snapshot of the runtime metrics of an AsyncWriter.
+type AsyncWriterStats struct {
+	// QueueDepth is the number of lines accepted by Write and not yet written.
+	QueueDepth int64
+	// HighWaterMark is the highest QueueDepth observed since the writer was created.
+	HighWaterMark int64
+	// LinesWritten is the number of lines handed to the underlying writer.
+	LinesWritten uint64
+	// BytesWritten is the number of bytes handed to the underlying writer.
+	BytesWritten uint64
+	// Drops is the number of lines rejected by Write.
+	Drops uint64
+	// Errors is the number of writes that failed on the underlying writer.
+	Errors uint64
+	// PoolMisses is the number of lines that found no preallocated buffer and fell back to the byte pools.
+	PoolMisses uint64
+	// WriteLatency is the distribution of the time spent in the underlying writer. Unless a hook is
+	// registered, only one in every 64 writes is timed.
+	WriteLatency LatencyHistogram
+}
+
+// LatencyHistogram is a histogram of write latencies with power of two microsecond buckets.
+type LatencyHistogram struct {
+	Buckets [latencyBuckets]uint64
+}
+
+// UpperBound returns the exclusive upper bound of the given bucket. The last bucket is unbounded and
+// returns zero.
+func (h LatencyHistogram) UpperBound(bucket int) time.Duration {
+	if bucket >= latencyBuckets-1 {
+		return 0
+	}
+	return time.Duration(1<<bucket) * time.Microsecond
+}
+
+// AsyncWriterHook receives AsyncWriter events as they happen. Hooks run on the producer goroutine for
+// drops and on the consumer goroutine for writes, so they must be cheap and safe for concurrent use.
+type AsyncWriterHook interface {
+	// OnWrite is called after every write to the underlying writer.
+	OnWrite(bytes int, latency time.Duration, err error)
+	// OnDrop is called when Write rejects a line.
+	OnDrop(bytes int, err error)
+}
+
+// WithHook registers a hook that is notified of every write and drop.
+func WithHook(hook AsyncWriterHook) AsyncWriterOption {
+	return func(asyncWriter *AsyncWriter) {
+		asyncWriter.hook = hook
+	}
+}
+
+// PublishExpvar publishes the stats of the given writer as an expvar variable with the given name.
+// Like expvar.Publish, it panics if the name is already registered.
+func PublishExpvar(name string, writer *AsyncWriter) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return writer.Stats()
+	}))
+}
+
+// Stats returns a snapshot of the runtime metrics of the writer.
+func (w *AsyncWriter) Stats() AsyncWriterStats {
+	lines := w.stats.lines.Load()
+	//nolint:exhaustruct
+	stats := AsyncWriterStats{
+		QueueDepth:    max(int64(w.stats.enqueued.Load()-lines), 0), //nolint:gosec
+		HighWaterMark: w.stats.highWater.Load(),
+		LinesWritten:  lines,
+		BytesWritten:  w.stats.bytes.Load(),
+		Drops:         w.stats.drops.Load(),
+		Errors:        w.stats.errors.Load(),
+		PoolMisses:    w.stats.poolMisses.Load(),
+	}
+	for idx := range w.stats.latency {
+		stats.WriteLatency.Buckets[idx] = w.stats.latency[idx].Load()
+	}
+	return stats
+}
+
+// cacheLineSize is used to keep producer and consumer counters on different cache lines.
+const cacheLineSize = 64
+
+// asyncStats holds the counters behind AsyncWriter.Stats. Producer counters and consumer counters are
+// padded apart so the consumer does not contend with Write.
+type asyncStats struct {
+	enqueued   atomic.Uint64
+	drops      atomic.Uint64
+	poolMisses atomic.Uint64
+	_          [cacheLineSize]byte
+	highWater  atomic.Int64
+	lines      atomic.Uint64
+	bytes      atomic.Uint64
+	errors     atomic.Uint64
+	latency    [latencyBuckets]atomic.Uint64
+}
+
+// sampled reports whether the next write should be timed.
+func (s *asyncStats) sampled() bool {
+	return s.lines.Load()%latencySampleRate == 0
+}
+
+// written records a line handed to the underlying writer. The high-water mark is tracked here rather
+// than in Write so producers only touch a single counter.
+func (s *asyncStats) written(numBytes int, err error) {
+	depth := int64(s.enqueued.Load() - s.lines.Add(1) + 1) //nolint:gosec
+	for {
+		highWater := s.highWater.Load()
+		if depth <= highWater || s.highWater.CompareAndSwap(highWater, depth) {
+			break
+		}
+	}
+	s.bytes.Add(uint64(numBytes)) //nolint:gosec
+	if err != nil {
+		s.errors.Add(1)
+	}
+}
+
+func (s *asyncStats) observe(latency time.Duration) {
+	bucket := min(bits.Len64(uint64(latency/time.Microsecond)), latencyBuckets-1) //nolint:gosec
+	s.latency[bucket].Add(1)
+}
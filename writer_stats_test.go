@@ -0,0 +1,133 @@
+package uslogs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Drathveloper/uslogs"
+)
+
+type recordingHook struct {
+	writes int
+	drops  int
+	errs   int
+	mu     sync.Mutex
+}
+
+func (h *recordingHook) OnWrite(_ int, _ time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writes++
+	if err != nil {
+		h.errs++
+	}
+}
+
+func (h *recordingHook) OnDrop(_ int, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drops++
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(_ []byte) (int, error) {
+	return 0, errors.New("boom")
+}
+
+func TestAsyncWriter_Stats(t *testing.T) {
+	var buf bytes.Buffer
+	//nolint:exhaustruct
+	hook := &recordingHook{}
+	w := uslogs.NewAsyncWriter(&buf, 2, uslogs.WithHook(hook))
+
+	for range 10 {
+		_, _ = w.Write([]byte("line\n"))
+	}
+	_ = w.Close()
+	_, _ = w.Write([]byte("late\n"))
+
+	stats := w.Stats()
+	if stats.LinesWritten != 10 || stats.BytesWritten != 50 {
+		t.Fatalf("got %d lines and %d bytes, expected 10 lines and 50 bytes", stats.LinesWritten, stats.BytesWritten)
+	}
+	if stats.QueueDepth != 0 || stats.HighWaterMark < 1 {
+		t.Fatalf("got depth %d and high-water mark %d, expected 0 and at least 1", stats.QueueDepth, stats.HighWaterMark)
+	}
+	if stats.Drops != 1 {
+		t.Fatalf("got %d drops, expected 1", stats.Drops)
+	}
+	if stats.PoolMisses == 0 {
+		t.Fatal("expected pool misses with only two preallocated buffers")
+	}
+	var histogramTotal uint64
+	for _, count := range stats.WriteLatency.Buckets {
+		histogramTotal += count
+	}
+	if histogramTotal != 10 {
+		t.Fatalf("got %d latency samples, expected every write to be timed with a hook", histogramTotal)
+	}
+	if hook.writes != 10 || hook.drops != 1 {
+		t.Fatalf("hook saw %d writes and %d drops, expected 10 and 1", hook.writes, hook.drops)
+	}
+}
+
+func TestAsyncWriter_StatsCountsErrors(t *testing.T) {
+	//nolint:exhaustruct
+	hook := &recordingHook{}
+	w := uslogs.NewAsyncWriter(failingWriter{}, 4, uslogs.WithRingBuffer(1024), uslogs.WithHook(hook))
+
+	_, _ = w.Write([]byte("line\n"))
+	_, _ = w.Write(make([]byte, 2048))
+	_ = w.Close()
+
+	stats := w.Stats()
+	if stats.Errors != 1 || stats.Drops != 1 || stats.QueueDepth != 0 {
+		t.Fatalf("got %d errors, %d drops and depth %d, expected 1, 1 and 0", stats.Errors, stats.Drops, stats.QueueDepth)
+	}
+	if hook.errs != 1 {
+		t.Fatalf("hook saw %d errors, expected 1", hook.errs)
+	}
+}
+
+func TestLatencyHistogram_UpperBound(t *testing.T) {
+	var histogram uslogs.LatencyHistogram
+
+	if got := histogram.UpperBound(0); got != time.Microsecond {
+		t.Fatalf("UpperBound(0) = %v, expected 1µs", got)
+	}
+	if got := histogram.UpperBound(10); got != 1024*time.Microsecond {
+		t.Fatalf("UpperBound(10) = %v, expected 1.024ms", got)
+	}
+	if got := histogram.UpperBound(len(histogram.Buckets) - 1); got != 0 {
+		t.Fatalf("UpperBound(last) = %v, expected 0", got)
+	}
+}
+
+// expvarRuns numbers the published variables, as expvar names can only be published once per process.
+var expvarRuns atomic.Int64 //nolint:gochecknoglobals
+
+func TestPublishExpvar(t *testing.T) {
+	var buf bytes.Buffer
+	w := uslogs.NewAsyncWriter(&buf, 4)
+	name := fmt.Sprintf("%s_%d", t.Name(), expvarRuns.Add(1))
+	uslogs.PublishExpvar(name, w)
+
+	_, _ = w.Write([]byte("line\n"))
+	_ = w.Close()
+
+	var stats uslogs.AsyncWriterStats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &stats); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.LinesWritten != 1 {
+		t.Fatalf("got %d lines from expvar, expected 1", stats.LinesWritten)
+	}
+}
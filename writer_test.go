@@ -3,9 +3,12 @@ package uslogs_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
@@ -224,3 +227,279 @@ func TestAsyncWriter_WithCanceledContext(t *testing.T) {
 		t.Fatal("no logs written")
 	}
 }
+
+func TestAsyncWriter_RingBuffer(t *testing.T) {
+	var buf bytes.Buffer
+	w := uslogs.NewAsyncWriter(&buf, 0, uslogs.WithRingBuffer(1024))
+
+	var wg sync.WaitGroup
+	total := 1000
+
+	for i := range total {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := w.Write(fmt.Appendf(nil, "msg-%d\n", i)); err != nil {
+				t.Errorf("write failed: %v", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	_ = w.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != total {
+		t.Fatalf("got %d lines, expected %d", len(lines), total)
+	}
+}
+
+func TestAsyncWriter_RingBufferLineTooLong(t *testing.T) {
+	var buf bytes.Buffer
+	w := uslogs.NewAsyncWriter(&buf, 0, uslogs.WithRingBuffer(64))
+
+	_, err := w.Write(make([]byte, 128))
+	if !errors.Is(err, uslogs.ErrLineTooLong) {
+		t.Fatalf("got error %v, expected %v", err, uslogs.ErrLineTooLong)
+	}
+	_ = w.Close()
+}
+
+func TestAsyncWriter_RingBufferCloseWaitsForDrain(t *testing.T) {
+	//nolint:exhaustruct
+	sw := &slowWriter{}
+	w := uslogs.NewAsyncWriter(sw, 0, uslogs.WithRingBuffer(64))
+
+	total := 50
+
+	for range total {
+		_, _ = w.Write([]byte("x\n"))
+	}
+
+	_ = w.Close()
+
+	if len(strings.Split(strings.TrimSpace(sw.String()), "\n")) != total {
+		t.Fatalf("not all messages were written")
+	}
+}
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return len(p), nil
+}
+
+func TestAsyncWriter_DropWhenFull(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []uslogs.AsyncWriterOption
+	}{
+		{name: "channel", opts: []uslogs.AsyncWriterOption{uslogs.WithDropWhenFull()}},
+		{name: "ring", opts: []uslogs.AsyncWriterOption{uslogs.WithDropWhenFull(), uslogs.WithRingBuffer(64)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bw := &blockingWriter{release: make(chan struct{})}
+			w := uslogs.NewAsyncWriter(bw, 1, test.opts...)
+
+			var err error
+			for range 100 {
+				if _, err = w.Write([]byte("0123456789\n")); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, uslogs.ErrBufferFull) {
+				t.Errorf("got error %v, expected %v", err, uslogs.ErrBufferFull)
+			}
+			close(bw.release)
+			_ = w.Close()
+		})
+	}
+}
+
+type lockedBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p) //nolint:wrapcheck
+}
+
+func (l *lockedBuffer) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Split(strings.TrimSpace(l.buf.String()), "\n")
+}
+
+func TestAsyncWriter_ShardsDeliverEveryLine(t *testing.T) {
+	for _, ringSize := range []int{0, 4096} {
+		//nolint:exhaustruct
+		buf := &lockedBuffer{}
+		w := uslogs.NewAsyncWriter(buf, 16, uslogs.WithShards(4), uslogs.WithRingBuffer(ringSize))
+
+		var wg sync.WaitGroup
+		total := 1000
+		for i := range total {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _ = w.Write(fmt.Appendf(nil, "msg-%d\n", i))
+			}(i)
+		}
+		wg.Wait()
+		_ = w.Close()
+
+		if lines := buf.Lines(); len(lines) != total {
+			t.Fatalf("ring size %d: got %d lines, expected %d", ringSize, len(lines), total)
+		}
+	}
+}
+
+func TestAsyncWriter_ShardsOrderPerKey(t *testing.T) {
+	//nolint:exhaustruct
+	buf := &lockedBuffer{}
+	w := uslogs.NewAsyncWriter(buf, 16, uslogs.WithShards(4), uslogs.WithOrdering(uslogs.OrderPerKey))
+	logger := slog.New(uslogs.NewUnstructuredHandler(uslogs.WithWriter(w)))
+
+	var wg sync.WaitGroup
+	producers, perProducer := 8, 200
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := uslogs.ContextWithOrderingKey(context.Background(), fmt.Sprint(p))
+			for i := range perProducer {
+				logger.InfoContext(ctx, fmt.Sprintf("%d-%d", p, i))
+				// Yielding lets the scheduler move the goroutine, which must not matter.
+				runtime.Gosched()
+			}
+		}()
+	}
+	wg.Wait()
+	_ = w.Close()
+
+	next := make([]int, producers)
+	for _, line := range buf.Lines() {
+		var p, i int
+		if _, err := fmt.Sscanf(line, "INFO %d-%d", &p, &i); err != nil {
+			t.Fatalf("unexpected line %q", line)
+		}
+		if i != next[p] {
+			t.Fatalf("producer %d: got line %d, expected %d", p, i, next[p])
+		}
+		next[p]++
+	}
+	for p, count := range next {
+		if count != perProducer {
+			t.Fatalf("producer %d: got %d lines, expected %d", p, count, perProducer)
+		}
+	}
+}
+
+func TestAsyncWriter_WriteKeyedWithoutOrdering(t *testing.T) {
+	var buf bytes.Buffer
+	w := uslogs.NewAsyncWriter(&buf, 16)
+
+	if _, err := w.WriteKeyed("key", []byte("keyed\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = w.Close()
+
+	if buf.String() != "keyed\n" {
+		t.Fatalf("got %q, expected %q", buf.String(), "keyed\n")
+	}
+}
+
+func TestAsyncWriter_ShardsOrderGlobal(t *testing.T) {
+	for _, ringSize := range []int{0, 4096} {
+		var buf bytes.Buffer
+		w := uslogs.NewAsyncWriter(&buf, 16,
+			uslogs.WithShards(4), uslogs.WithOrdering(uslogs.OrderGlobal), uslogs.WithRingBuffer(ringSize))
+
+		total := 2000
+		for i := range total {
+			_, _ = w.Write(fmt.Appendf(nil, "%d\n", i))
+		}
+		_ = w.Close()
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != total {
+			t.Fatalf("ring size %d: got %d lines, expected %d", ringSize, len(lines), total)
+		}
+		for i, line := range lines {
+			if line != fmt.Sprint(i) {
+				t.Fatalf("ring size %d: line %d is %q, expected %q", ringSize, i, line, fmt.Sprint(i))
+			}
+		}
+	}
+}
+
+// gatedBuffer blocks every write until release is closed, then keeps the lines.
+type gatedBuffer struct {
+	release chan struct{}
+	lockedBuffer
+}
+
+func (g *gatedBuffer) Write(p []byte) (int, error) {
+	<-g.release
+	return g.lockedBuffer.Write(p)
+}
+
+func TestAsyncWriter_ShardsOrderGlobalBoundsPendingLines(t *testing.T) {
+	//nolint:exhaustruct
+	buf := &gatedBuffer{release: make(chan struct{})}
+	w := uslogs.NewAsyncWriter(buf, 16,
+		uslogs.WithShards(2), uslogs.WithOrdering(uslogs.OrderGlobal), uslogs.WithDropWhenFull())
+
+	accepted := 0
+	for i := range 20000 {
+		if _, err := w.Write(fmt.Appendf(nil, "%d\n", i)); err == nil {
+			accepted++
+		} else if !errors.Is(err, uslogs.ErrBufferFull) {
+			t.Fatalf("got error %v, expected %v", err, uslogs.ErrBufferFull)
+		}
+	}
+	// The stalled writer holds one line, and the merger accepts at most 16384 more.
+	if accepted == 20000 || accepted > 16385 {
+		t.Fatalf("got %d accepted lines, expected the merger to stop accepting lines", accepted)
+	}
+	close(buf.release)
+	_ = w.Close()
+
+	lines := buf.Lines()
+	if len(lines) != accepted {
+		t.Fatalf("got %d lines, expected %d", len(lines), accepted)
+	}
+	previous := -1
+	for _, line := range lines {
+		var i int
+		if _, err := fmt.Sscanf(line, "%d", &i); err != nil || i <= previous {
+			t.Fatalf("got line %q after %d, expected increasing lines", line, previous)
+		}
+		previous = i
+	}
+}
+
+func TestAsyncWriter_ShardsOrderGlobalSkipsRejectedLines(t *testing.T) {
+	var buf bytes.Buffer
+	w := uslogs.NewAsyncWriter(&buf, 0,
+		uslogs.WithShards(2), uslogs.WithOrdering(uslogs.OrderGlobal), uslogs.WithRingBuffer(64))
+
+	_, _ = w.Write([]byte("first\n"))
+	if _, err := w.Write(make([]byte, 128)); !errors.Is(err, uslogs.ErrLineTooLong) {
+		t.Fatalf("got error %v, expected %v", err, uslogs.ErrLineTooLong)
+	}
+	_, _ = w.Write([]byte("second\n"))
+	_ = w.Close()
+
+	if buf.String() != "first\nsecond\n" {
+		t.Fatalf("got %q, expected %q", buf.String(), "first\nsecond\n")
+	}
+}